@@ -19,19 +19,23 @@ var (
 	txTimeoutDefault               = 10 * time.Second
 	waitConfirmationTimeoutDefault = 50 * time.Second
 	statusCheckTimeDefault         = 1 * time.Second
+	// waitBlockhashInvalidTimeout is the max time of waitConfirmationOrBlockhashInvalid
+	waitBlockhashInvalidTimeout = 3 * time.Minute
 )
 
-func Transfer(c *client.Client, sender types.Account, feePayer types.Account, receiverPubkey string, txTimeout time.Duration) (txHash string, pingErr PingResultError) {
+func Transfer(c *client.Client, sender types.Account, feePayer types.Account, receiverPubkey string, txTimeout time.Duration, stages *StageTimer) (txHash string, pingErr PingResultError) {
 	// to fetch recent blockhash
-	res, err := c.GetLatestBlockhash(context.Background())
+	stages.Start()
+	res, err := c.GetLatestBlockhashAndContext(context.Background())
 	if err != nil {
 		log.Println("Failed to get latest blockhash, err: ", err)
 		return "", PingResultError(fmt.Sprintf("Failed to get latest blockhash, err: %v", err))
 	}
+	stages.BlockhashFetched(res.Context.Slot)
 	// create a message
 	message := types.NewMessage(types.NewMessageParam{
 		FeePayer:        feePayer.PublicKey,
-		RecentBlockhash: res.Blockhash, // recent blockhash
+		RecentBlockhash: res.Value.Blockhash, // recent blockhash
 		Instructions: []types.Instruction{
 			sysprog.Transfer(sysprog.TransferParam{
 				From:   sender.PublicKey,                           // from
@@ -55,12 +59,14 @@ func Transfer(c *client.Client, sender types.Account, feePayer types.Account, re
 		txTimeout = time.Duration(txTimeoutDefault)
 	}
 	ctx, _ := context.WithTimeout(context.TODO(), txTimeout)
+	stages.SendStart(getProcessedSlotAsync(c))
 	txHash, err = c.SendTransaction(ctx, tx)
 
 	if err != nil {
 		log.Printf("Error: Failed to send tx, err: %v", err)
 		return "", PingResultError(fmt.Sprintf("Failed to send a tx, err: %v", err))
 	}
+	stages.Sent()
 	return txHash, EmptyPingResultError
 }
 
@@ -69,7 +75,8 @@ type SendPingTxParam struct {
 	FeePayer            types.Account
 	RequestComputeUnits uint32
 	ComputeUnitPrice    uint64 // micro lamports
-	ReceiverPubkey      string
+	Tx                  PingTx
+	Stages              *StageTimer
 }
 
 func SendPingTx(param SendPingTxParam) (string, string, PingResultError) {
@@ -83,7 +90,8 @@ func SendPingTx(param SendPingTxParam) (string, string, PingResultError) {
 		time.Sleep(10 * time.Millisecond)
 
 		// Get a recent blockhash.
-		latestBlockhashResponse, err := param.Client.GetLatestBlockhashWithConfig(
+		param.Stages.Start()
+		latestBlockhashResponse, err := param.Client.GetLatestBlockhashAndContextWithConfig(
 			context.Background(),
 			client.GetLatestBlockhashConfig{
 				Commitment: rpc.CommitmentConfirmed,
@@ -93,33 +101,34 @@ func SendPingTx(param SendPingTxParam) (string, string, PingResultError) {
 			errRecords = append(errRecords, fmt.Sprintf("failed to get the latest blockhash, err: %v", err))
 			continue
 		}
-		blockhash := latestBlockhashResponse.Blockhash
+		blockhash := latestBlockhashResponse.Value.Blockhash
+		param.Stages.BlockhashFetched(latestBlockhashResponse.Context.Slot)
 
-		// Generate a random amount for trasferring. This entropy is needed to
+		// Generate a random nonce for the ping tx. This entropy is needed to
 		// ensure we don't send duplicates in cases where the blockhash hasn't
 		// moved between pings.
 		rand.Seed(time.Now().UnixNano())
-		amount := uint64(rand.Intn(1000)) + 1
+		nonce := uint64(rand.Intn(1000)) + 1
 
-		// Construct the tx.
+		// Construct the tx. Compute budget instructions are added only if they are set.
+		instructions := []types.Instruction{}
+		if param.RequestComputeUnits > 0 {
+			instructions = append(instructions, cmptbdgprog.SetComputeUnitLimit(cmptbdgprog.SetComputeUnitLimitParam{
+				Units: param.RequestComputeUnits,
+			}))
+		}
+		if param.ComputeUnitPrice > 0 {
+			instructions = append(instructions, cmptbdgprog.SetComputeUnitPrice(cmptbdgprog.SetComputeUnitPriceParam{
+				MicroLamports: param.ComputeUnitPrice,
+			}))
+		}
+		instructions = append(instructions, param.Tx.Instructions(param.FeePayer.PublicKey, nonce)...)
 		tx, err := types.NewTransaction(types.NewTransactionParam{
 			Signers: []types.Account{param.FeePayer},
 			Message: types.NewMessage(types.NewMessageParam{
 				FeePayer:        param.FeePayer.PublicKey,
 				RecentBlockhash: blockhash,
-				Instructions: []types.Instruction{
-					cmptbdgprog.SetComputeUnitLimit(cmptbdgprog.SetComputeUnitLimitParam{
-						Units: param.RequestComputeUnits,
-					}),
-					cmptbdgprog.SetComputeUnitPrice(cmptbdgprog.SetComputeUnitPriceParam{
-						MicroLamports: param.ComputeUnitPrice,
-					}),
-					sysprog.Transfer(sysprog.TransferParam{
-						From:   param.FeePayer.PublicKey,
-						To:     common.PublicKeyFromString(param.ReceiverPubkey),
-						Amount: amount,
-					}),
-				},
+				Instructions:    instructions,
 			}),
 		})
 		if err != nil {
@@ -128,6 +137,7 @@ func SendPingTx(param SendPingTxParam) (string, string, PingResultError) {
 		}
 
 		// Send the tx.
+		param.Stages.SendStart(getProcessedSlotAsync(param.Client))
 		txhash, err := param.Client.SendTransactionWithConfig(
 			context.Background(),
 			tx,
@@ -139,6 +149,7 @@ func SendPingTx(param SendPingTxParam) (string, string, PingResultError) {
 			errRecords = append(errRecords, fmt.Sprintf("failed to send the ping tx, err: %v", err))
 			continue
 		}
+		param.Stages.Sent()
 		return txhash, blockhash, PingResultError("")
 	}
 
@@ -152,7 +163,7 @@ func SendPingTx(param SendPingTxParam) (string, string, PingResultError) {
 
 */
 
-func waitConfirmation(c *client.Client, txHash string, timeout time.Duration, requestTimeout time.Duration, checkInterval time.Duration) PingResultError {
+func waitConfirmation(c *client.Client, txHash string, timeout time.Duration, requestTimeout time.Duration, checkInterval time.Duration, stages *StageTimer) PingResultError {
 	if timeout <= 0 {
 		timeout = waitConfirmationTimeoutDefault
 		log.Println("timeout is not set! Use default timeout", timeout, " sec")
@@ -171,6 +182,7 @@ func waitConfirmation(c *client.Client, txHash string, timeout time.Duration, re
 			}
 		}
 		if resp != nil {
+			stages.Seen(*resp.ConfirmationStatus)
 			if *resp.ConfirmationStatus == rpc.CommitmentConfirmed || *resp.ConfirmationStatus == rpc.CommitmentFinalized {
 				return EmptyPingResultError
 			}
@@ -189,10 +201,12 @@ func waitConfirmation(c *client.Client, txHash string, timeout time.Duration, re
 	}
 }
 
-func waitConfirmationOrBlockhashInvalid(c *client.Client, txHash, blockhash string) PingResultError {
-	startTime := time.Now()
-	endTime := startTime.Add(3 * time.Minute)
+func waitConfirmationOrBlockhashInvalid(c *client.Client, txHash, blockhash string, stages *StageTimer) PingResultError {
+	return waitConfirmationOrBlockhashInvalidUntil(c, txHash, blockhash, time.Now().Add(waitBlockhashInvalidTimeout), stages)
+}
 
+// waitConfirmationOrBlockhashInvalidUntil is waitConfirmationOrBlockhashInvalid which gives up at endTime
+func waitConfirmationOrBlockhashInvalidUntil(c *client.Client, txHash, blockhash string, endTime time.Time, stages *StageTimer) PingResultError {
 	for time.Now().Before(endTime) {
 		time.Sleep(1 * time.Second)
 
@@ -215,6 +229,7 @@ func waitConfirmationOrBlockhashInvalid(c *client.Client, txHash, blockhash stri
 			continue
 		}
 		commitment := *getSignatureStatus.ConfirmationStatus
+		stages.Seen(commitment)
 		if commitment == rpc.CommitmentConfirmed || commitment == rpc.CommitmentFinalized {
 			// Ping has landed!
 			return EmptyPingResultError
@@ -225,6 +240,56 @@ func waitConfirmationOrBlockhashInvalid(c *client.Client, txHash, blockhash stri
 	return PingResultError(fmt.Sprintf("the confirmation process exceeds 3 mins, txHash: %v, blockhash: %v", txHash, blockhash))
 }
 
+// getProcessedSlotAsync fetch the processed slot of the rpc node in background, so the tx is sent without waiting for it.
+// The channel receives 0 if it fails or takes longer than WaitConfirmationQueryTimeout
+func getProcessedSlotAsync(c *client.Client) <-chan uint64 {
+	ret := make(chan uint64, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(WaitConfirmationQueryTimeout)*time.Second)
+		defer cancel()
+		slot, err := c.GetSlotWithConfig(ctx, client.GetSlotConfig{Commitment: rpc.CommitmentProcessed})
+		if err != nil {
+			slot = 0
+		}
+		ret <- slot
+	}()
+	return ret
+}
+
+// getLandedSlot return the slot of the block which includes a confirmed tx. 0 if it fails or takes longer than WaitConfirmationQueryTimeout
+func getLandedSlot(c *client.Client, txHash string) uint64 {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(WaitConfirmationQueryTimeout)*time.Second)
+	defer cancel()
+	tx, err := c.GetTransactionWithConfig(ctx, txHash, client.GetTransactionConfig{Commitment: rpc.CommitmentConfirmed})
+	if err != nil || tx == nil {
+		return 0
+	}
+	return tx.Slot
+}
+
+// waitFinalized keep checking the status of a confirmed tx until it is finalized. Only the finalized stage is affected
+func waitFinalized(c *client.Client, txHash string, timeout time.Duration, checkInterval time.Duration, stages *StageTimer) {
+	if timeout <= 0 {
+		timeout = waitConfirmationTimeoutDefault
+	}
+	if checkInterval <= 0 {
+		checkInterval = statusCheckTimeDefault
+	}
+	endTime := time.Now().Add(timeout)
+	for time.Now().Before(endTime) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(WaitConfirmationQueryTimeout)*time.Second)
+		resp, err := c.GetSignatureStatus(ctx, txHash)
+		cancel()
+		if err == nil && resp != nil && resp.ConfirmationStatus != nil {
+			stages.Seen(*resp.ConfirmationStatus)
+			if *resp.ConfirmationStatus == rpc.CommitmentFinalized {
+				return
+			}
+		}
+		time.Sleep(checkInterval)
+	}
+}
+
 func isBlockhashValid(c *client.Client, ctx context.Context, blockhash string) (bool, error) {
 	// check for confirmed commitment
 	b1, err := c.IsBlockhashValidWithConfig(
@@ -5,15 +5,32 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const AlertTriggerNameLength = 30
 
+// AlertEvent is a threshold level change of an AlertTrigger which sends an alert. It is stored in database
+type AlertEvent struct {
+	ID           uint  `gorm:"primaryKey"`
+	TimeStamp    int64 `gorm:"index;NOT NULL"`
+	Cluster      Cluster
+	Hostname     string
+	Trigger      string
+	Loss         float64 // percentage
+	OldLevel     int     // threshold index before the change
+	NewLevel     int
+	OldThreshold float64 // percentage
+	NewThreshold float64
+	Memo         string
+}
+
 type AlertTrigger struct {
 	Name            string
 	LastLoss        float64
 	CurrentLoss     float64
 	ThresholdIndex  int
+	LastIndex       int // ThresholdIndex before the last ShouldAlertSend
 	ThresholdLevels []float64
 	ThresholdAsc    bool
 	FilePath        string
@@ -93,8 +110,14 @@ func (s *AlertTrigger) ReadIndexFromFile() int {
 	return 0
 }
 
+// LevelChanged reports whether the last ShouldAlertSend moved the threshold level
+func (s *AlertTrigger) LevelChanged() bool {
+	return s.LastIndex != s.ThresholdIndex
+}
+
 // Doing rule here
 func (s *AlertTrigger) ShouldAlertSend() bool {
+	s.LastIndex = s.ThresholdIndex
 	if s.ThresholdLevels[0] == 0 {
 		return true
 	}
@@ -123,3 +146,19 @@ func (s *AlertTrigger) ShouldAlertSend() bool {
 	log.Println(s.Name, " trigger ", "ThresholdLevel NOT change. Loss:", s.CurrentLoss, "Index:", s.ThresholdIndex)
 	return false
 }
+
+// NewAlertEvent create the AlertEvent of the last ShouldAlertSend
+func (s *AlertTrigger) NewAlertEvent(cConf ClusterConfig, memo string) AlertEvent {
+	return AlertEvent{
+		TimeStamp:    time.Now().UTC().Unix(),
+		Cluster:      cConf.Cluster,
+		Hostname:     cConf.HostName,
+		Trigger:      s.Name,
+		Loss:         s.CurrentLoss,
+		OldLevel:     s.LastIndex,
+		NewLevel:     s.ThresholdIndex,
+		OldThreshold: s.ThresholdLevels[s.LastIndex],
+		NewThreshold: s.ThresholdLevels[s.ThresholdIndex],
+		Memo:         memo,
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"time"
 )
 
-// collect ping result and divided them into a group by each min
-type Group1Min struct {
+// DefaultGroupWindow is the default size (in seconds) of a group
+const DefaultGroupWindow = int64(60)
+
+// collect ping result and divided them into a group by each window. TimeStamp is the end of the window
+type PingGroup struct {
 	Result    []PingResult
 	TimeStamp int64
+	Window    int64
 }
 
 // statistic of ping result take-time
@@ -19,6 +23,9 @@ type TimeStatistic struct {
 	Max    int64
 	Stddev float64
 	Sum    int64
+	P50    int64
+	P90    int64
+	P99    int64
 }
 
 // statistic of ping result
@@ -31,6 +38,11 @@ type PingSatistic struct {
 	TimeStatistic
 	Errors    []string
 	TimeStamp int64
+	Window    int64
+	// count of samples by compute unit price
+	FeeDistribution map[uint64]int
+	StageMeasure    StageMeasure
+	SlotMeasure     SlotMeasure
 }
 
 // statistic without a group
@@ -40,6 +52,8 @@ type GlobalStatistic struct {
 	Loss      float64
 	Count     int
 	TimeStatistic
+	Stages [NumPingStages]TimeStatistic
+	Slots  SlotMeasure
 }
 
 // All statistic Data
@@ -48,6 +62,13 @@ type GroupsAllStatistic struct {
 	RawPingStaticList    []PingSatistic
 	GlobalErrorStatistic map[string]int
 	GlobalStatistic
+	FeeTiers []FeeTierStatistic // statistic of each tier of FeeLadder. only set for reports
+}
+
+// FeeTierStatistic is the statistic of the txs of a compute unit price
+type FeeTierStatistic struct {
+	ComputeUnitPrice uint64
+	GlobalStatistic
 }
 
 func (g *GroupsAllStatistic) GetGroupsAllStatistic(raw bool) GlobalStatistic {
@@ -55,12 +76,15 @@ func (g *GroupsAllStatistic) GetGroupsAllStatistic(raw bool) GlobalStatistic {
 	var sumOfSubmitted, sumOfConfirmed float64
 	var sumOfCount int
 	var sumTimeMeasure TakeTime
+	var sumStageMeasure StageMeasure
 	if !raw {
 		for _, pg := range g.PingStatisticList {
 			sumOfSubmitted += pg.Submitted
 			sumOfConfirmed += pg.Confirmed
 			sumOfCount += pg.Count
 			sumTimeMeasure.Times = append(sumTimeMeasure.Times, pg.TimeMeasure.Times...)
+			sumStageMeasure.Merge(&pg.StageMeasure)
+			groupStat.Slots.Merge(&pg.SlotMeasure)
 		}
 
 	} else { // raw data (without exception)
@@ -82,44 +106,99 @@ func (g *GroupsAllStatistic) GetGroupsAllStatistic(raw bool) GlobalStatistic {
 		groupStat.Loss = 0
 	}
 
-	tMax, tMean, tMin, tStddev, tSum := sumTimeMeasure.Statistic()
-	groupStat.TimeStatistic = TimeStatistic{
-		Min:    tMin,
-		Mean:   tMean,
-		Max:    tMax,
-		Stddev: tStddev,
-		Sum:    tSum,
-	}
+	groupStat.TimeStatistic = sumTimeMeasure.TimeStatistic()
+	groupStat.Stages = sumStageMeasure.TimeStatistic()
 	return groupStat
 }
 
-// grouping1Min: group []PingResult into 1 min group.
-func grouping1Min(pr []PingResult, startTime int64, endTime int64) []Group1Min {
-	window := int64(60)
-	groups := []Group1Min{}
-	for periodend := endTime; periodend > startTime; periodend = periodend - window {
-		minGroup := Group1Min{}
-		for _, pResult := range pr {
-			if pResult.TimeStamp <= periodend && pResult.TimeStamp > periodend-window {
-				minGroup.Result = append(minGroup.Result, pResult)
-			}
+// groupingWindow: group []PingResult into groups of window seconds.
+// Groups are ordered from the latest; the first group is (endTime-window, endTime].
+// Every window in (startTime, endTime] has a group even if it has no result.
+func groupingWindow(pr []PingResult, startTime int64, endTime int64, window int64) []PingGroup {
+	if window <= 0 {
+		window = DefaultGroupWindow
+	}
+	if endTime <= startTime {
+		return []PingGroup{}
+	}
+	numGroups := (endTime - startTime + window - 1) / window
+	groups := make([]PingGroup, numGroups)
+	for i := range groups {
+		groups[i].TimeStamp = endTime - int64(i)*window
+		groups[i].Window = window
+	}
+	for _, pResult := range pr {
+		if pResult.TimeStamp > endTime {
+			continue
 		}
-		minGroup.TimeStamp = periodend
-		// debug: printPingResultGroup(minGroup.Result, periodend, periodend-window)
-		groups = append(groups, minGroup)
+		idx := (endTime - pResult.TimeStamp) / window
+		if idx >= numGroups {
+			continue
+		}
+		groups[idx].Result = append(groups[idx].Result, pResult)
 	}
 	return groups
 }
 
-func statisticCompute(cConf ClusterConfig, groups []Group1Min) *GroupsAllStatistic {
+// statisticComputeStream compute the same statistic as statisticCompute(cConf, groupingWindow(results, startTime, endTime, window))
+// from results which each feeds in time order. Only the results of one group are kept in memory.
+// Return nil if there is no result.
+func statisticComputeStream(cConf ClusterConfig, startTime int64, endTime int64, window int64, each func(func(*PingResult) error) error) (*GroupsAllStatistic, error) {
+	groups := groupingWindow(nil, startTime, endTime, window)
+	stat := statisticCompute(cConf, groups)
+	hasData := false
+	current := -1
+	var results []PingResult
+	closeGroup := func() {
+		if len(results) == 0 {
+			return
+		}
+		g := groups[current]
+		g.Result = results
+		groupStat := statisticCompute(cConf, []PingGroup{g})
+		stat.PingStatisticList[current] = groupStat.PingStatisticList[0]
+		stat.RawPingStaticList[current] = groupStat.RawPingStaticList[0]
+		for e, n := range groupStat.GlobalErrorStatistic {
+			stat.GlobalErrorStatistic[e] += n
+		}
+		results = nil
+	}
+	err := each(func(r *PingResult) error {
+		if r.TimeStamp > endTime || len(groups) == 0 {
+			return nil
+		}
+		idx := int((endTime - r.TimeStamp) / groups[0].Window)
+		if idx >= len(groups) {
+			return nil
+		}
+		if idx != current {
+			closeGroup()
+			current = idx
+		}
+		hasData = true
+		results = append(results, *r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	closeGroup()
+	if !hasData {
+		return nil, nil
+	}
+	return stat, nil
+}
+
+func statisticCompute(cConf ClusterConfig, groups []PingGroup) *GroupsAllStatistic {
 	stat := GroupsAllStatistic{}
 	stat.PingStatisticList = []PingSatistic{}
 	stat.RawPingStaticList = []PingSatistic{}
 	stat.GlobalErrorStatistic = make(map[string]int)
 
 	for _, group := range groups {
-		filterGroupStat := PingSatistic{}
-		rawGroupStat := PingSatistic{}
+		// every group needs a ts to present itself, even if it has no data
+		filterGroupStat := PingSatistic{TimeStamp: group.TimeStamp, Window: group.Window, FeeDistribution: map[uint64]int{}}
+		rawGroupStat := PingSatistic{TimeStamp: group.TimeStamp, Window: group.Window}
 		for _, singlePing := range group.Result {
 			errorException := false
 			errorCount := len(singlePing.Error)
@@ -135,24 +214,31 @@ func statisticCompute(cConf ClusterConfig, groups []Group1Min) *GroupsAllStatist
 				}
 			}
 			// Raw Data Statistic
-			rawGroupStat.TimeStamp = group.TimeStamp
 			rawGroupStat.Submitted += float64(singlePing.Submitted)
 			rawGroupStat.Confirmed += float64(singlePing.Confirmed)
 			rawGroupStat.Count += 1
-			rawGroupStat.TimeMeasure.AddTime(singlePing.TakeTime)
+			for _, t := range singlePing.ConfirmationTimes() {
+				rawGroupStat.TimeMeasure.AddTime(t)
+			}
 			// Data Statistic (Filtered by error filter)
-			filterGroupStat.TimeStamp = group.TimeStamp // Need a ts to present the group
 			if !errorException {
 				filterGroupStat.Submitted += float64(singlePing.Submitted)
 				filterGroupStat.Confirmed += float64(singlePing.Confirmed)
 				filterGroupStat.Count += 1
-				if errorCount <= 0 {
-					filterGroupStat.TimeMeasure.AddTime(singlePing.TakeTime)
-				} else if (errorCount > 0) && !errorException { // general error is considered as a timeout
+				filterGroupStat.FeeDistribution[singlePing.ComputeUnitPrice]++
+				for _, s := range singlePing.StagesOfTxs() {
+					filterGroupStat.StageMeasure.Add(s)
+				}
+				filterGroupStat.SlotMeasure.AddResult(&singlePing)
+				// percentiles are of txs, not of batches
+				for _, t := range singlePing.ConfirmationTimes() {
+					filterGroupStat.TimeMeasure.AddTime(t)
+				}
+				for i := 0; i < errorCount; i++ { // each failed tx of a general error is considered as a timeout
 					t := time.Duration(cConf.PingConfig.TxTimeout) * time.Second
 					filterGroupStat.TimeMeasure.AddTime(t.Milliseconds())
-				} // if StatisticErrorExceptionList , do not count as a satistic
-			}
+				}
+			} // if StatisticErrorExceptionList , do not count as a satistic
 		}
 		// raw data
 		if rawGroupStat.Submitted == 0 { // no data
@@ -160,14 +246,7 @@ func statisticCompute(cConf ClusterConfig, groups []Group1Min) *GroupsAllStatist
 		} else {
 			rawGroupStat.Loss = (rawGroupStat.Submitted - rawGroupStat.Confirmed) / rawGroupStat.Submitted
 		}
-		tMax, tMean, tMin, tStddev, tSum := rawGroupStat.TimeMeasure.Statistic()
-		rawGroupStat.TimeStatistic = TimeStatistic{
-			Min:    tMin,
-			Mean:   tMean,
-			Max:    tMax,
-			Stddev: tStddev,
-			Sum:    tSum,
-		}
+		rawGroupStat.TimeStatistic = rawGroupStat.TimeMeasure.TimeStatistic()
 		stat.RawPingStaticList = append(stat.RawPingStaticList, rawGroupStat)
 
 		// data with filter
@@ -177,14 +256,7 @@ func statisticCompute(cConf ClusterConfig, groups []Group1Min) *GroupsAllStatist
 			filterGroupStat.Loss = (filterGroupStat.Submitted - filterGroupStat.Confirmed) / filterGroupStat.Submitted
 		}
 
-		tMax, tMean, tMin, tStddev, tSum = filterGroupStat.TimeMeasure.Statistic()
-		filterGroupStat.TimeStatistic = TimeStatistic{
-			Min:    tMin,
-			Mean:   tMean,
-			Max:    tMax,
-			Stddev: tStddev,
-			Sum:    tSum,
-		}
+		filterGroupStat.TimeStatistic = filterGroupStat.TimeMeasure.TimeStatistic()
 		stat.PingStatisticList = append(stat.PingStatisticList, filterGroupStat)
 	}
 	return &stat
@@ -198,15 +270,17 @@ func printPingResultGroup(pr []PingResult, from int64, to int64) {
 
 func printStatistic(cConf ClusterConfig, stat *GroupsAllStatistic) {
 	for i, g := range stat.PingStatisticList {
-		statisticTime := fmt.Sprintf("min/mean/max/stddev ms = %d/%3.0f/%d/%3.0f",
-			g.TimeStatistic.Min, g.TimeStatistic.Mean, g.TimeStatistic.Max, g.TimeStatistic.Stddev)
+		statisticTime := fmt.Sprintf("min/mean/max/stddev ms = %d/%3.0f/%d/%3.0f p50/p90/p99 ms = %d/%d/%d",
+			g.TimeStatistic.Min, g.TimeStatistic.Mean, g.TimeStatistic.Max, g.TimeStatistic.Stddev,
+			g.TimeStatistic.P50, g.TimeStatistic.P90, g.TimeStatistic.P99)
 
 		log.Println(fmt.Sprintf("%d->{ hostname: %s, submitted: %3.0f,confirmed:%3.0f, loss: %3.1f%s, count:%d %s}",
 			i, cConf.HostName, g.Submitted, g.Confirmed, g.Loss*100, "%", g.Count, statisticTime))
 	}
 	for i, g := range stat.RawPingStaticList {
-		statisticTime := fmt.Sprintf("min/mean/max/stddev ms = %d/%3.0f/%d/%3.0f",
-			g.TimeStatistic.Min, g.TimeStatistic.Mean, g.TimeStatistic.Max, g.TimeStatistic.Stddev)
+		statisticTime := fmt.Sprintf("min/mean/max/stddev ms = %d/%3.0f/%d/%3.0f p50/p90/p99 ms = %d/%d/%d",
+			g.TimeStatistic.Min, g.TimeStatistic.Mean, g.TimeStatistic.Max, g.TimeStatistic.Stddev,
+			g.TimeStatistic.P50, g.TimeStatistic.P90, g.TimeStatistic.P99)
 		errString := ""
 		for _, v := range g.Errors {
 			errString = errString + v + "\n"
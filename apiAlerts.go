@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertEventJSON is an alert event. Loss and thresholds are percentages
+type AlertEventJSON struct {
+	TimeStamp     string  `json:"ts"`
+	TimeStampUnix int64   `json:"ts_unix"`
+	Cluster       string  `json:"cluster"`
+	Hostname      string  `json:"hostname"`
+	Trigger       string  `json:"trigger"`
+	Loss          float64 `json:"loss"`
+	Direction     string  `json:"direction"` // up or down
+	OldLevel      int     `json:"old_level"`
+	NewLevel      int     `json:"new_level"`
+	OldThreshold  float64 `json:"old_threshold"`
+	NewThreshold  float64 `json:"new_threshold"`
+	Memo          string  `json:"memo"`
+}
+
+// alertHistory return alert events of the cluster in (from, to]. The latest is the first.
+func alertHistory(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	from, to, err := parseTimeRangeParams(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ret := []AlertEventJSON{}
+	for _, e := range getAlertEvents(cluster, from, to, 0) {
+		ret = append(ret, AlertEventToJson(e))
+	}
+	c.IndentedJSON(http.StatusOK, ret)
+}
+
+func AlertEventToJson(e AlertEvent) AlertEventJSON {
+	direction := "up"
+	if e.NewLevel < e.OldLevel {
+		direction = "down"
+	}
+	return AlertEventJSON{
+		TimeStamp:     time.Unix(e.TimeStamp, 0).UTC().Format(time.RFC3339),
+		TimeStampUnix: e.TimeStamp,
+		Cluster:       clusterRouteName(e.Cluster),
+		Hostname:      e.Hostname,
+		Trigger:       e.Trigger,
+		Loss:          e.Loss,
+		Direction:     direction,
+		OldLevel:      e.OldLevel,
+		NewLevel:      e.NewLevel,
+		OldThreshold:  e.OldThreshold,
+		NewThreshold:  e.NewThreshold,
+		Memo:          e.Memo,
+	}
+}
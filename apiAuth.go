@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// APIKeyHeader is the header to carry an api key. Keys in query are not accepted because urls are logged
+	APIKeyHeader = "X-API-Key"
+	// limiterIdleTimeout remove a public client limiter which is idle longer than limiterIdleTimeout
+	limiterIdleTimeout = 10 * time.Minute
+	// maxPublicClients is the max number of public client limiters. New clients share one limiter when it is full
+	maxPublicClients = 10000
+	// overflowClient is the key of the limiter shared by new clients when public limiters are full
+	overflowClient = "overflow"
+)
+
+// paths which do not need authentication
+var authExemptPaths = map[string]bool{
+	"/health": true,
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	quotaDay int64
+	used     int64
+}
+
+// apiAuth check api keys and limit request rate of each key or each public client
+type apiAuth struct {
+	conf      APIAuth
+	mutex     sync.Mutex
+	keys      map[string]*clientLimiter // key -> limiter
+	keyConfs  map[string]APIKey
+	public    map[string]*clientLimiter // client ip -> limiter
+	lastSweep time.Time
+	proxies   []*net.IPNet // trusted proxies
+}
+
+func newAPIAuth(conf APIAuth) *apiAuth {
+	a := &apiAuth{
+		conf:      conf,
+		keys:      map[string]*clientLimiter{},
+		keyConfs:  map[string]APIKey{},
+		public:    map[string]*clientLimiter{},
+		lastSweep: time.Now(),
+	}
+	for _, p := range conf.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			log.Println("invalid TrustedProxies ", p, " ignored")
+			continue
+		}
+		a.proxies = append(a.proxies, ipNet)
+	}
+	for _, k := range conf.Keys {
+		if len(k.Key) == 0 {
+			continue
+		}
+		a.keyConfs[k.Key] = k
+		a.keys[k.Key] = &clientLimiter{limiter: newLimiter(k.RateLimit, k.Burst)}
+	}
+	return a
+}
+
+// newLimiter create a limiter of ratePerSec requests per second. ratePerSec <= 0 means no limit
+func newLimiter(ratePerSec float64, burst int) *rate.Limiter {
+	if ratePerSec <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(ratePerSec))
+	}
+	return rate.NewLimiter(rate.Limit(ratePerSec), burst)
+}
+
+// newClusterAuths create the apiAuth of each cluster whose auth is enabled. They are shared by all api servers,
+// so a cluster has the same keys and rate limits on every server.
+func newClusterAuths() map[Cluster]*apiAuth {
+	auths := map[Cluster]*apiAuth{}
+	for _, cConf := range config.Clusters {
+		if cConf.APIServer.Auth.Enabled {
+			auths[cConf.Cluster] = newAPIAuth(cConf.APIServer.Auth)
+		}
+	}
+	return auths
+}
+
+// apiAuthMiddleware authenticate api keys and limit request rate by the auth of each cluster which the request reads.
+// Requests of clusters whose auth is not enabled pass. See requestClusters for the clusters of a request.
+func apiAuthMiddleware(own Cluster, auths map[Cluster]*apiAuth, clusters []Cluster) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authExemptPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		for _, cluster := range requestClusters(c, own, clusters) {
+			auth, ok := auths[cluster]
+			if !ok {
+				continue
+			}
+			retryAfter, status := auth.allow(c.GetHeader(APIKeyHeader), auth.clientIP(c.Request))
+			if status != http.StatusOK {
+				if retryAfter > 0 {
+					c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+				}
+				c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status)})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// requestClusters return the clusters whose data the request reads: the cluster in the url, the clusters of /compare,
+// the clusters of /status, or the cluster which owns the server for other paths and unknown clusters.
+func requestClusters(c *gin.Context, own Cluster, clusters []Cluster) []Cluster {
+	if route := c.Param("cluster"); len(route) > 0 {
+		if cluster, ok := findClusterByRoute(route); ok {
+			return []Cluster{cluster}
+		}
+		return []Cluster{own}
+	}
+	switch c.FullPath() {
+	case "/compare":
+		ret := []Cluster{own}
+		for _, route := range strings.Split(c.Query("clusters"), ",") {
+			cluster, ok := findClusterByRoute(strings.TrimSpace(route))
+			if ok && !containCluster(ret, cluster) {
+				ret = append(ret, cluster)
+			}
+		}
+		return ret
+	case "/status":
+		return clusters
+	}
+	return []Cluster{own}
+}
+
+func containCluster(clusters []Cluster, cluster Cluster) bool {
+	for _, c := range clusters {
+		if c == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// adminAuthMiddleware allow requests with an admin key of the cluster in the url only.
+// Admin keys work even if auth is not enabled.
+func adminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := []byte(c.GetHeader(APIKeyHeader))
+		if len(key) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": http.StatusText(http.StatusUnauthorized)})
+			return
+		}
+		if cluster, ok := findClusterByRoute(c.Param("cluster")); ok {
+			for _, k := range GetClusterConfig(cluster).APIServer.Auth.Keys {
+				if k.Admin && len(k.Key) > 0 && subtle.ConstantTimeCompare([]byte(k.Key), key) == 1 {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": http.StatusText(http.StatusForbidden)})
+	}
+}
+
+// clientIP return the ip of the peer. If the peer is a trusted proxy, it is the right-most X-Forwarded-For address
+// which is not a trusted proxy. Addresses left of it can be set by the client, so they are never used.
+func (a *apiAuth) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !a.isTrustedProxy(host) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		host = ip
+		if !a.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return host
+}
+
+func (a *apiAuth) isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, p := range a.proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow return http.StatusOK if the request is allowed. Otherwise return the status and time to retry
+func (a *apiAuth) allow(key string, clientIP string) (time.Duration, int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	a.sweep(now)
+	if len(key) == 0 {
+		if !a.conf.PublicAccess {
+			return 0, http.StatusUnauthorized
+		}
+		l, ok := a.public[clientIP]
+		if !ok && len(a.public) >= maxPublicClients {
+			clientIP = overflowClient
+			l, ok = a.public[clientIP]
+		}
+		if !ok {
+			l = &clientLimiter{limiter: newLimiter(a.conf.PublicRateLimit, a.conf.PublicBurst)}
+			a.public[clientIP] = l
+		}
+		l.lastSeen = now
+		return reserve(l.limiter, now)
+	}
+	l, conf, ok := a.findKey(key)
+	if !ok {
+		return 0, http.StatusUnauthorized
+	}
+	l.lastSeen = now
+	if conf.DailyQuota > 0 {
+		day := now.UTC().Unix() / (24 * 60 * 60)
+		if l.quotaDay != day {
+			l.quotaDay = day
+			l.used = 0
+		}
+		if l.used >= conf.DailyQuota {
+			nextDay := time.Unix((day+1)*24*60*60, 0)
+			return nextDay.Sub(now), http.StatusTooManyRequests
+		}
+	}
+	retryAfter, status := reserve(l.limiter, now)
+	if status == http.StatusOK {
+		l.used++
+	}
+	return retryAfter, status
+}
+
+// findKey compare keys in constant time
+func (a *apiAuth) findKey(key string) (*clientLimiter, APIKey, bool) {
+	for k, conf := range a.keyConfs {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return a.keys[k], conf, true
+		}
+	}
+	return nil, APIKey{}, false
+}
+
+// sweep remove idle public limiters. must be called with mutex locked
+func (a *apiAuth) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < time.Minute {
+		return
+	}
+	a.lastSweep = now
+	for ip, l := range a.public {
+		if now.Sub(l.lastSeen) > limiterIdleTimeout {
+			delete(a.public, ip)
+		}
+	}
+}
+
+func reserve(limiter *rate.Limiter, now time.Time) (time.Duration, int) {
+	r := limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return time.Second, http.StatusTooManyRequests
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay, http.StatusTooManyRequests
+	}
+	return 0, http.StatusOK
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultAvailabilityLossThreshold is the default loss (percentage) under which a minute is up
+	DefaultAvailabilityLossThreshold = float64(20)
+	// DefaultAvailabilityLatencySLO is the default mean confirmation time (ms) under which a minute is up
+	DefaultAvailabilityLatencySLO = int64(20000)
+)
+
+// availabilityWindows are the time ranges of an availability report
+var availabilityWindows = []struct {
+	Name     string
+	Duration int64
+}{{"24h", 24 * 60 * 60}, {"7d", 7 * 24 * 60 * 60}, {"30d", 30 * 24 * 60 * 60}}
+
+// OutageJSON is a run of consecutive down minutes. Minutes without data do not end an outage.
+type OutageJSON struct {
+	Start     string `json:"start"`
+	StartUnix int64  `json:"start_unix"`
+	End       string `json:"end"`
+	EndUnix   int64  `json:"end_unix"`
+	Minutes   int    `json:"minutes"`
+}
+
+// AvailabilityWindowJSON is the availability of a time range. Availability is null if no minute has data.
+type AvailabilityWindowJSON struct {
+	Window          string      `json:"window"`
+	From            int64       `json:"from"`
+	To              int64       `json:"to"`
+	MeasuredMinutes int         `json:"measured_minutes"`
+	NoDataMinutes   int         `json:"no_data_minutes"`
+	DowntimeMinutes int         `json:"downtime_minutes"`
+	Availability    *float64    `json:"availability"` // percentage of measured minutes which are up
+	LongestOutage   *OutageJSON `json:"longest_outage"`
+}
+
+// AvailabilityJSON is the availability of a cluster over 24h, 7d and 30d
+type AvailabilityJSON struct {
+	Cluster       string                   `json:"cluster"`
+	LossThreshold float64                  `json:"loss_threshold"`
+	LatencySLO    int64                    `json:"latency_slo_ms"`
+	Windows       []AvailabilityWindowJSON `json:"windows"`
+}
+
+// minuteAvailability is whether a minute with data is down
+type minuteAvailability struct {
+	TimeStamp int64 // end of the minute
+	Down      bool
+}
+
+// availability return the percentage of minutes whose loss < loss_threshold and mean confirmation time <= latency_slo.
+// Minutes are computed with the same error filter as statisticCompute.
+func availability(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	cConf := GetClusterConfig(cluster)
+	lossThreshold := cConf.APIServer.Availability.LossThreshold
+	if t := c.Query("loss_threshold"); len(t) > 0 {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil || v <= 0 || v > 100 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidLossThreshold.Error()})
+			return
+		}
+		lossThreshold = v
+	}
+	latencySLO := cConf.APIServer.Availability.LatencySLO
+	if t := c.Query("latency_slo"); len(t) > 0 {
+		v, err := strconv.ParseInt(t, 10, 64)
+		if err != nil || v <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidLatencySLO.Error()})
+			return
+		}
+		latencySLO = v
+	}
+	filter, err := parseFeeFilterParams(c, cluster, HasComputeUnitPrice)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ret, err := GetAvailability(c.Request.Context(), filter, lossThreshold, latencySLO)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, ret)
+}
+
+// GetAvailability compute the availability of the last 24h, 7d and 30d ended at the last complete minute.
+// From/To of the filter are ignored.
+func GetAvailability(ctx context.Context, f PingResultFilter, lossThreshold float64, latencySLO int64) (AvailabilityJSON, error) {
+	ret := AvailabilityJSON{Cluster: clusterRouteName(f.Cluster), LossThreshold: lossThreshold, LatencySLO: latencySLO, Windows: []AvailabilityWindowJSON{}}
+	end := time.Now().UTC().Unix() / 60 * 60
+	f.PingType = DataPoint1Min
+	f.From, f.To = end-availabilityWindows[len(availabilityWindows)-1].Duration, end
+	minutes, err := getMinuteAvailability(ctx, f, lossThreshold, latencySLO)
+	if err != nil {
+		return ret, err
+	}
+	for _, w := range availabilityWindows {
+		ret.Windows = append(ret.Windows, availabilityOfWindow(minutes, w.Name, end-w.Duration, end))
+	}
+	return ret, nil
+}
+
+// getMinuteAvailability group PingResult by minute and tell whether each minute is down. Minutes without data are not returned.
+func getMinuteAvailability(ctx context.Context, f PingResultFilter, lossThreshold float64, latencySLO int64) ([]minuteAvailability, error) {
+	cConf := GetClusterConfig(f.Cluster)
+	minutes := []minuteAvailability{}
+	group := PingGroup{Window: 60}
+	closeGroup := func() {
+		if len(group.Result) == 0 {
+			return
+		}
+		stat := statisticCompute(cConf, []PingGroup{group}).PingStatisticList[0]
+		if stat.Count > 0 { // all results are in StatisticErrorExceptionList is treated as no data
+			down := stat.Loss*100 >= lossThreshold || stat.TimeStatistic.Mean > float64(latencySLO)
+			minutes = append(minutes, minuteAvailability{TimeStamp: group.TimeStamp, Down: down})
+		}
+		group.Result = nil
+	}
+	err := forEachPingResult(ctx, f, func(r *PingResult) error {
+		minuteEnd := (r.TimeStamp + 59) / 60 * 60
+		if minuteEnd != group.TimeStamp {
+			closeGroup()
+			group.TimeStamp = minuteEnd
+		}
+		group.Result = append(group.Result, *r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	closeGroup()
+	return minutes, nil
+}
+
+// availabilityOfWindow summarize minutes ended in (from, to]. minutes must be in time order
+func availabilityOfWindow(minutes []minuteAvailability, name string, from int64, to int64) AvailabilityWindowJSON {
+	ret := AvailabilityWindowJSON{Window: name, From: from, To: to}
+	var cur, longest *OutageJSON
+	for _, m := range minutes {
+		if m.TimeStamp <= from || m.TimeStamp > to {
+			continue
+		}
+		ret.MeasuredMinutes++
+		if !m.Down {
+			cur = nil
+			continue
+		}
+		ret.DowntimeMinutes++
+		if cur == nil {
+			cur = &OutageJSON{StartUnix: m.TimeStamp - 60}
+		}
+		cur.EndUnix = m.TimeStamp
+		cur.Minutes++
+		if longest == nil || cur.Minutes > longest.Minutes {
+			longest = cur
+		}
+	}
+	ret.NoDataMinutes = int((to-from)/60) - ret.MeasuredMinutes
+	if ret.MeasuredMinutes > 0 {
+		a := float64(ret.MeasuredMinutes-ret.DowntimeMinutes) / float64(ret.MeasuredMinutes) * 100
+		ret.Availability = &a
+	}
+	if longest != nil {
+		longest.Start = time.Unix(longest.StartUnix, 0).UTC().Format(time.RFC3339)
+		longest.End = time.Unix(longest.EndUnix, 0).UTC().Format(time.RFC3339)
+		ret.LongestOutage = longest
+	}
+	return ret
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxCompareClusters is the max number of clusters in a compare request. Each cluster is a query of the range
+const MaxCompareClusters = 5
+
+// CompareClusterJSON is the statistic of a cluster in a group. Deltas are against the baseline cluster
+// and are null for the baseline itself or if either cluster has no data in the group.
+type CompareClusterJSON struct {
+	Count            int           `json:"count"`
+	Submitted        int           `json:"submitted"`
+	Confirmed        int           `json:"confirmed"`
+	Loss             float64       `json:"loss"` // 0 ~ 1
+	LatencyMs        LatencyV2JSON `json:"latency_ms"`
+	LossDelta        *float64      `json:"loss_delta"`
+	LatencyMeanDelta *float64      `json:"latency_mean_delta_ms"`
+	LatencyP90Delta  *int64        `json:"latency_p90_delta_ms"`
+}
+
+// ComparePointJSON is the statistic of each cluster in the same group. Clusters are keyed by route name
+type ComparePointJSON struct {
+	TimeStamp     string                        `json:"ts"`
+	TimeStampUnix int64                         `json:"ts_unix"`
+	Clusters      map[string]CompareClusterJSON `json:"clusters"`
+}
+
+// CompareJSON is the aligned statistic of several clusters
+type CompareJSON struct {
+	Clusters  []string           `json:"clusters"`
+	Baseline  string             `json:"baseline"`
+	WindowSec int64              `json:"window_sec"`
+	Data      []ComparePointJSON `json:"data"`
+}
+
+// compareClusters return the statistic of clusters in the same groups of (from, to].
+// clusters is route names separated by comma. baseline is one of them, default is the first.
+func compareClusters(c *gin.Context) {
+	routes := []string{}
+	clusters := []Cluster{}
+	seen := map[Cluster]bool{}
+	for _, route := range strings.Split(c.Query("clusters"), ",") {
+		route = strings.TrimSpace(route)
+		cluster, ok := clusterFromParam(route)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidCompareClusters.Error()})
+			return
+		}
+		if !seen[cluster] {
+			seen[cluster] = true
+			routes = append(routes, route)
+			clusters = append(clusters, cluster)
+		}
+	}
+	if len(clusters) < 2 || len(clusters) > MaxCompareClusters {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidCompareClusters.Error()})
+		return
+	}
+	baseline := c.DefaultQuery("baseline", routes[0])
+	baselineIndex := -1
+	for i, route := range routes {
+		if route == baseline {
+			baselineIndex = i
+		}
+	}
+	if baselineIndex < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidBaseline.Error()})
+		return
+	}
+	from, to, step, err := parseRangeParams(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseFeeFilterParams(c, clusters[0], HasComputeUnitPrice)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	ret, err := GetCompare(c.Request.Context(), filter, clusters, routes, baselineIndex, step)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, ret)
+}
+
+// GetCompare compute the statistic of each cluster with the same filter and put the same groups together.
+// Rows of each cluster are streamed from the database.
+func GetCompare(ctx context.Context, f PingResultFilter, clusters []Cluster, routes []string, baselineIndex int, step int64) (CompareJSON, error) {
+	ret := CompareJSON{Clusters: routes, Baseline: routes[baselineIndex], WindowSec: step, Data: []ComparePointJSON{}}
+	stats := make([][]PingSatistic, len(clusters))
+	for i, cluster := range clusters {
+		f.Cluster = cluster
+		groupsStat, err := getRangeStatistic(ctx, f, step)
+		if err != nil {
+			return ret, err
+		}
+		if groupsStat == nil { // no data, but every cluster needs the same groups
+			groupsStat = statisticCompute(GetClusterConfig(cluster), groupingWindow(nil, f.From, f.To, step))
+		}
+		stats[i] = groupsStat.PingStatisticList
+	}
+	// every cluster has the same groups because from/to/step are the same
+	for g := range stats[baselineIndex] {
+		base := stats[baselineIndex][g]
+		point := ComparePointJSON{
+			TimeStamp:     time.Unix(base.TimeStamp, 0).UTC().Format(time.RFC3339),
+			TimeStampUnix: base.TimeStamp,
+			Clusters:      map[string]CompareClusterJSON{},
+		}
+		for i := range clusters {
+			point.Clusters[routes[i]] = compareClusterPoint(&stats[i][g], &base, i == baselineIndex)
+		}
+		ret.Data = append(ret.Data, point)
+	}
+	return ret, nil
+}
+
+func compareClusterPoint(stat *PingSatistic, base *PingSatistic, isBaseline bool) CompareClusterJSON {
+	v2 := PingStatisticToV2Json(stat)
+	ret := CompareClusterJSON{
+		Count:     v2.Count,
+		Submitted: v2.Submitted,
+		Confirmed: v2.Confirmed,
+		Loss:      v2.Loss,
+		LatencyMs: v2.LatencyMs,
+	}
+	if isBaseline || stat.Count == 0 || base.Count == 0 {
+		return ret
+	}
+	lossDelta := stat.Loss - base.Loss
+	meanDelta := stat.TimeStatistic.Mean - base.TimeStatistic.Mean
+	p90Delta := stat.TimeStatistic.P90 - base.TimeStatistic.P90
+	ret.LossDelta, ret.LatencyMeanDelta, ret.LatencyP90Delta = &lossDelta, &meanDelta, &p90Delta
+	return ret
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorBreakdownPointJSON is the count of each error category in a group
+type ErrorBreakdownPointJSON struct {
+	TimeStamp     string         `json:"ts"`
+	TimeStampUnix int64          `json:"ts_unix"`
+	Total         int            `json:"total"`
+	Errors        map[string]int `json:"errors"`
+}
+
+// ErrorBreakdownJSON is the error categories over time
+type ErrorBreakdownJSON struct {
+	Categories []string                  `json:"categories"`
+	WindowSec  int64                     `json:"window_sec"`
+	Data       []ErrorBreakdownPointJSON `json:"data"`
+}
+
+// errorBreakdown return the count of each error category grouped by step in (from, to].
+// All errors are counted, including errors which are not used in statistic.
+func errorBreakdown(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	from, to, step, err := parseRangeParams(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseFeeFilterParams(c, cluster, AllData)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	ret, err := GetErrorBreakdown(c.Request.Context(), filter, step)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, ret)
+}
+
+// ErrorCategories return short names of ResponseErrIdentifierList and OtherErrorCategory
+func ErrorCategories() []string {
+	categories := []string{}
+	exist := map[string]bool{}
+	for _, idf := range ResponseErrIdentifierList {
+		if !exist[idf.Short] {
+			exist[idf.Short] = true
+			categories = append(categories, idf.Short)
+		}
+	}
+	return append(categories, OtherErrorCategory)
+}
+
+// GetErrorBreakdown count errors of PingResult matched the filter by category and group them by step seconds.
+// Rows are streamed from the database.
+func GetErrorBreakdown(ctx context.Context, f PingResultFilter, step int64) (ErrorBreakdownJSON, error) {
+	return errorBreakdownOf(f.From, f.To, step, func(fn func(*PingResult) error) error {
+		return forEachPingResult(ctx, f, fn)
+	})
+}
+
+// errorBreakdownOf count errors of results which each feeds in (from, to] by category and group them by step seconds
+func errorBreakdownOf(from int64, to int64, step int64, each func(func(*PingResult) error) error) (ErrorBreakdownJSON, error) {
+	ret := ErrorBreakdownJSON{Categories: ErrorCategories(), WindowSec: step, Data: []ErrorBreakdownPointJSON{}}
+	groups := groupingWindow(nil, from, to, step)
+	points := make([]ErrorBreakdownPointJSON, len(groups))
+	for i, group := range groups {
+		points[i] = ErrorBreakdownPointJSON{
+			TimeStamp:     time.Unix(group.TimeStamp, 0).UTC().Format(time.RFC3339),
+			TimeStampUnix: group.TimeStamp,
+			Errors:        map[string]int{},
+		}
+		for _, category := range ret.Categories {
+			points[i].Errors[category] = 0
+		}
+	}
+	hasData := false
+	err := each(func(r *PingResult) error {
+		if r.TimeStamp > to || len(groups) == 0 {
+			return nil
+		}
+		idx := int((to - r.TimeStamp) / groups[0].Window)
+		if idx >= len(groups) {
+			return nil
+		}
+		hasData = true
+		for _, e := range r.Error {
+			points[idx].Errors[PingResultError(e).Category()]++
+			points[idx].Total++
+		}
+		return nil
+	})
+	if err != nil {
+		return ret, err
+	}
+	if hasData {
+		ret.Data = points
+	}
+	return ret, nil
+}
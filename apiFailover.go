@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FailoverEndpointJSON is the state of an endpoint. The access token is never returned
+type FailoverEndpointJSON struct {
+	Index          int    `json:"index"`
+	Endpoint       string `json:"endpoint"`
+	HasAccessToken bool   `json:"has_access_token"`
+	Priority       int    `json:"priority"`
+	Retry          int    `json:"retry"`
+	MaxRetry       int    `json:"max_retry"`
+	LastError      string `json:"last_error"`
+	LastErrorTime  string `json:"last_error_time,omitempty"`
+	Active         bool   `json:"active"`
+}
+
+// FailoverStateJSON is the state of all endpoints of a cluster
+type FailoverStateJSON struct {
+	Cluster   string                 `json:"cluster"`
+	Active    int                    `json:"active"`
+	Endpoints []FailoverEndpointJSON `json:"endpoints"`
+}
+
+func failoverFromParam(c *gin.Context) (Cluster, *RPCFailover, bool) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		return cluster, nil, false
+	}
+	f := GetClusterFailover(cluster)
+	return cluster, f, f != nil
+}
+
+func getFailoverState(c *gin.Context) {
+	cluster, f, ok := failoverFromParam(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, FailoverStateToJson(cluster, f))
+}
+
+// switchFailoverEndpoint force workers of the cluster to use the endpoint of index query
+func switchFailoverEndpoint(c *gin.Context) {
+	cluster, f, ok := failoverFromParam(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	index, err := strconv.Atoi(c.Query("index"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidEndpointIndex.Error()})
+		return
+	}
+	endpoint, err := f.SwitchTo(index)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Println("admin switch ", cluster, " endpoint to index ", index, " from ", c.ClientIP())
+	metricFailoverSwitches.WithLabelValues(string(cluster), endpoint.Endpoint).Inc()
+	c.IndentedJSON(http.StatusOK, FailoverStateToJson(cluster, f))
+}
+
+// resetFailoverRetry reset retry counts of all endpoints of the cluster
+func resetFailoverRetry(c *gin.Context) {
+	cluster, f, ok := failoverFromParam(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	f.ResetRetry()
+	log.Println("admin reset ", cluster, " endpoint retries from ", c.ClientIP())
+	c.IndentedJSON(http.StatusOK, FailoverStateToJson(cluster, f))
+}
+
+// FailoverStateToJson convert the state of RPCFailover to FailoverStateJSON
+func FailoverStateToJson(cluster Cluster, f *RPCFailover) FailoverStateJSON {
+	active, endpoints := f.Snapshot()
+	ret := FailoverStateJSON{Cluster: clusterRouteName(cluster), Active: active, Endpoints: []FailoverEndpointJSON{}}
+	for i, e := range endpoints {
+		j := FailoverEndpointJSON{
+			Index:          i,
+			Endpoint:       e.Endpoint,
+			HasAccessToken: len(e.AccessToken) > 0,
+			Priority:       e.Piority,
+			Retry:          e.Retry,
+			MaxRetry:       e.MaxRetry,
+			LastError:      e.LastError,
+			Active:         i == active,
+		}
+		if e.LastErrorTime > 0 {
+			j.LastErrorTime = time.Unix(e.LastErrorTime, 0).UTC().Format(time.RFC3339)
+		}
+		ret.Endpoints = append(ret.Endpoints, j)
+	}
+	return ret
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeeTierJSON is the statistic of samples with the same compute unit price
+type FeeTierJSON struct {
+	ComputeUnitPrice uint64  `json:"compute_unit_price"`
+	Count            int     `json:"count"`
+	Submitted        int     `json:"submitted"`
+	Confirmed        int     `json:"confirmed"`
+	Loss             float64 `json:"loss"` // 0 ~ 1
+	Mean             int64   `json:"mean_ms"`
+	P50              int64   `json:"p50_ms"`
+	P90              int64   `json:"p90_ms"`
+	P99              int64   `json:"p99_ms"`
+}
+
+// FeeDistributionJSON is the fee distribution of the matched samples
+type FeeDistributionJSON struct {
+	From    int64         `json:"from"`
+	To      int64         `json:"to"`
+	Samples int           `json:"samples"`
+	Tiers   []FeeTierJSON `json:"tiers"`
+}
+
+// feeDistribution return landing rate and take time of each compute unit price in (from, to].
+// price/threshold/min_price/max_price/price_tier/fee_strategy query filter the samples. default is all data
+func feeDistribution(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	from, to, err := parseTimeRangeParams(c)
+	if err == nil && to-from > MaxAggregateRange {
+		err = ErrRangeTooLong
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseFeeFilterParams(c, cluster, AllData)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	c.IndentedJSON(http.StatusOK, GetFeeDistribution(filter))
+}
+
+// GetFeeDistribution compute the statistic of each compute unit price of PingResult matched the filter
+func GetFeeDistribution(f PingResultFilter) FeeDistributionJSON {
+	ret := FeeDistributionJSON{From: f.From, To: f.To, Tiers: []FeeTierJSON{}}
+	tiers := map[uint64][]PingResult{}
+	for _, r := range getByFilter(f) {
+		tiers[r.ComputeUnitPrice] = append(tiers[r.ComputeUnitPrice], r)
+	}
+	cConf := GetClusterConfig(f.Cluster)
+	for price, results := range tiers {
+		groupsStat := statisticCompute(cConf, []PingGroup{{Result: results, TimeStamp: f.To, Window: f.To - f.From}})
+		stat := groupsStat.PingStatisticList[0]
+		ret.Samples += len(results)
+		tier := FeeTierJSON{
+			ComputeUnitPrice: price,
+			Count:            stat.Count,
+			Submitted:        int(stat.Submitted),
+			Confirmed:        int(stat.Confirmed),
+			Mean:             int64(stat.TimeStatistic.Mean),
+			P50:              stat.TimeStatistic.P50,
+			P90:              stat.TimeStatistic.P90,
+			P99:              stat.TimeStatistic.P99,
+		}
+		if stat.Count > 0 {
+			tier.Loss = stat.Loss
+		}
+		ret.Tiers = append(ret.Tiers, tier)
+	}
+	sort.Slice(ret.Tiers, func(i, j int) bool { return ret.Tiers[i].ComputeUnitPrice < ret.Tiers[j].ComputeUnitPrice })
+	return ret
+}
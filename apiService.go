@@ -1,114 +1,135 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/timeout"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/crypto/acme/autocert"
 )
 
-func APIService(c ClustersToRun) {
-	runCluster := func(mode ConnectionMode, host string, hostSSL string, key string, crt string) {
+// MaxRangeGroups is the max number of groups a range query returns (7 days of 1 min groups)
+const MaxRangeGroups = 7 * 24 * 60
+
+// MaxAggregateRange is the max time range (seconds) of queries which load all rows to aggregate, e.g. fees
+const MaxAggregateRange = MaxRangeGroups * 60
+
+// ShutdownTimeout is the max time to wait for in-flight requests when the api servers shut down
+const ShutdownTimeout = 30 * time.Second
+
+// APIService start api servers of the clusters and return the started servers. It does not block.
+func APIService(c ClustersToRun) []*http.Server {
+	servers := []*http.Server{}
+	auths := newClusterAuths()
+	runCluster := func(cluster Cluster, conf APIServer) {
+		mode, host, hostSSL := conf.Mode, conf.IP, conf.SSLIP
 		router := gin.Default()
+		router.Use(apiAuthMiddleware(cluster, auths, c.Clusters()))
 		router.GET("/:cluster/latest", getLatest)
 		router.GET("/:cluster/last6hours", timeout.New(timeout.WithTimeout(10*time.Second), timeout.WithHandler(last6hours)))
 		router.GET("/:cluster/last6hours/nocomputeprice", timeout.New(timeout.WithTimeout(10*time.Second), timeout.WithHandler(last6hoursNoPrice)))
 		router.GET("/:cluster/last6hours/all", timeout.New(timeout.WithTimeout(10*time.Second), timeout.WithHandler(last6hoursAll)))
+		router.GET("/:cluster/range", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(timeRange)))
 		router.GET("/health", health)
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 		router.GET("/:cluster/rpc", getRPCEndpoint)
-		if mode == HTTPS {
-			err := router.RunTLS(hostSSL, crt, key)
-			if err != nil {
-				log.Panic("api service is not up!!!", err)
-				panic("api service is not up!!!")
-			}
-			log.Println("HTTPS server is up!", " Server:", host)
-		} else if mode == HTTP {
-			err := router.Run(host)
+		router.GET("/:cluster/failover", getFailoverState)
+		router.POST("/:cluster/failover/switch", adminAuthMiddleware(), switchFailoverEndpoint)
+		router.POST("/:cluster/failover/reset", adminAuthMiddleware(), resetFailoverRetry)
+		router.GET("/:cluster/stream", streamPingResult)
+		router.GET("/:cluster/export", exportPingResult)
+		router.GET("/:cluster/fees", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(feeDistribution)))
+		router.GET("/:cluster/errors", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(errorBreakdown)))
+		router.GET("/:cluster/alerts", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(alertHistory)))
+		router.GET("/:cluster/availability", timeout.New(timeout.WithTimeout(60*time.Second), timeout.WithHandler(availability)))
+		router.GET("/compare", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(compareClusters)))
+		router.GET("/status", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(dashboard(c.Clusters()))))
+		registerV2Routes(router)
+		var tlsConfig *tls.Config
+		var httpHandler http.Handler = router
+		if mode == HTTPS || mode == BOTH {
+			var acmeManager *autocert.Manager
+			var err error
+			tlsConfig, acmeManager, err = newServerTLSConfig(conf)
 			if err != nil {
 				log.Panic("api service is not up!!!", err)
-				panic("api service is not up!!!")
 			}
-			log.Println("HTTP server is up!", " Server", host)
-		} else if config.Mainnet.APIServer.Mode == BOTH {
-			err := router.RunTLS(host, crt, key)
-			if err != nil {
-				log.Panic("api service is not up!!!", err)
-				panic("api service is not up!!!")
+			if acmeManager != nil { // serve http-01 challenges
+				httpHandler = acmeManager.HTTPHandler(router)
 			}
-			log.Println("HTTPS server is up!", " Server:", host)
-			err = router.Run(host)
-			log.Println("HTTP server is up!", " Server", host)
-			if err != nil {
-				log.Panic("api service is not up!!!", err)
-				panic("api service is not up!!!")
-			}
-		} else {
+		}
+		switch mode {
+		case HTTP:
+			servers = append(servers, startServer(httpHandler, host, nil))
+		case HTTPS:
+			servers = append(servers, startServer(router, hostSSL, tlsConfig))
+		case BOTH:
+			servers = append(servers, startServer(httpHandler, host, nil))
+			servers = append(servers, startServer(router, hostSSL, tlsConfig))
+		default:
 			log.Panic("Invalid ServerSetup Mode")
 		}
 	}
-	// Single Cluster or all Cluster
-	switch c {
-	case RunMainnetBeta:
-		if config.Mainnet.APIServer.Enabled {
-			go runCluster(config.Mainnet.APIServer.Mode,
-				config.Mainnet.APIServer.IP,
-				config.Mainnet.APIServer.SSLIP,
-				config.Mainnet.APIServer.KeyPath,
-				config.Mainnet.APIServer.CrtPath)
-			log.Println("--- API Server Mainnet Start--- ")
-		}
-
-	case RunTestnet:
-		if config.Testnet.APIServer.Enabled {
-			go runCluster(config.Testnet.APIServer.Mode,
-				config.Testnet.APIServer.IP,
-				config.Testnet.APIServer.SSLIP,
-				config.Testnet.APIServer.KeyPath,
-				config.Testnet.APIServer.CrtPath)
-			log.Println("--- API Server Testnet Start--- ")
+	for _, cluster := range c.Clusters() {
+		cConf := GetClusterConfig(cluster)
+		if cConf.APIServer.Enabled {
+			runCluster(cluster, cConf.APIServer)
+			log.Println("--- API Server ", cConf.Name, " Start--- ")
 		}
+	}
+	return servers
+}
 
-	case RunDevnet:
-		if config.Devnet.APIServer.Enabled {
-			go runCluster(config.Devnet.APIServer.Mode,
-				config.Devnet.APIServer.IP,
-				config.Devnet.APIServer.SSLIP,
-				config.Devnet.APIServer.KeyPath,
-				config.Devnet.APIServer.CrtPath)
-			log.Println("--- API Server Devnet Start--- ")
-		}
-	case RunAllClusters:
-		if config.Mainnet.APIServer.Enabled {
-			go runCluster(config.Mainnet.APIServer.Mode,
-				config.Mainnet.APIServer.IP,
-				config.Mainnet.APIServer.SSLIP,
-				config.Mainnet.APIServer.KeyPath,
-				config.Mainnet.APIServer.CrtPath)
-			log.Println("--- API Server Mainnet Start--- ")
-		}
-		if config.Testnet.APIServer.Enabled {
-			go runCluster(config.Testnet.APIServer.Mode,
-				config.Testnet.APIServer.IP,
-				config.Testnet.APIServer.SSLIP,
-				config.Testnet.APIServer.KeyPath,
-				config.Testnet.APIServer.CrtPath)
-			log.Println("--- API Server Testnet Start--- ")
+// startServer run a http server in background. It serves HTTPS if tlsConfig is given.
+// Requests contexts are canceled on shutdown so that long-lived streams end.
+func startServer(handler http.Handler, addr string, tlsConfig *tls.Config) *http.Server {
+	baseCtx, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     handler,
+		TLSConfig:   tlsConfig,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.RegisterOnShutdown(cancel)
+	go func() {
+		var err error
+		if tlsConfig != nil { // certificates are provided by tlsConfig
+			log.Println("HTTPS server is up!", " Server:", addr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Println("HTTP server is up!", " Server:", addr)
+			err = srv.ListenAndServe()
 		}
-		if config.Devnet.APIServer.Enabled {
-			go runCluster(config.Devnet.APIServer.Mode,
-				config.Devnet.APIServer.IP,
-				config.Devnet.APIServer.SSLIP,
-				config.Devnet.APIServer.KeyPath,
-				config.Devnet.APIServer.CrtPath)
-			log.Println("--- API Server Devnet Start--- ")
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Panic("api service is not up!!!", err)
 		}
+	}()
+	return srv
+}
 
-	default:
-		panic(ErrInvalidCluster)
+// shutdownServers stop accepting new connections and wait for in-flight requests until ctx is done
+func shutdownServers(ctx context.Context, servers []*http.Server) {
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Println("api server ", srv.Addr, " shutdown error:", err)
+				srv.Close()
+			}
+		}(srv)
 	}
+	wg.Wait()
 }
 
 func health(c *gin.Context) {
@@ -116,20 +137,13 @@ func health(c *gin.Context) {
 }
 
 func getRPCEndpoint(c *gin.Context) {
-	cluster := c.Param("cluster")
-	var e *FailoverEndpoint
-	switch cluster {
-	case "mainnet-beta":
-		e = mainnetFailover.GetEndpoint()
-	case "testnet":
-		e = testnetFailover.GetEndpoint()
-	case "devnet":
-		e = devnetFailover.GetEndpoint()
-	default:
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
 		c.AbortWithStatus(http.StatusNotFound)
-		log.Println("StatusNotFound Error:", cluster)
 		return
 	}
+	index, endpoints := GetClusterFailover(cluster).Snapshot()
+	e := endpoints[index]
 	// to avoid leak of token
 	c.IndentedJSON(http.StatusOK, FailoverEndpoint{
 		Endpoint: e.Endpoint,
@@ -139,71 +153,227 @@ func getRPCEndpoint(c *gin.Context) {
 }
 
 func getLatest(c *gin.Context) {
-	cluster := c.Param("cluster")
-	var ret DataPoint1MinResultJSON
-	switch cluster {
-	case "mainnet-beta":
-		ret = GetLatestResult(MainnetBeta)
-	case "testnet":
-		ret = GetLatestResult(Testnet)
-	case "devnet":
-		ret = GetLatestResult(Devnet)
-	default:
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
 		c.AbortWithStatus(http.StatusNotFound)
-		log.Println("StatusNotFound Error:", cluster)
 		return
 	}
-	c.IndentedJSON(http.StatusOK, ret)
+	c.IndentedJSON(http.StatusOK, GetLatestResult(cluster))
 }
 func last6hours(c *gin.Context) {
-	cluster := c.Param("cluster")
-	var ret []DataPoint1MinResultJSON
-	switch cluster {
-	case "mainnet-beta":
-		ret = GetLast6hours(MainnetBeta, HasComputeUnitPrice, 0)
-	case "testnet":
-		ret = GetLast6hours(Testnet, HasComputeUnitPrice, 0)
-	case "devnet":
-		ret = GetLast6hours(Devnet, HasComputeUnitPrice, 0)
-	default:
+	last6hoursByPrice(c, HasComputeUnitPrice)
+}
+
+func last6hoursNoPrice(c *gin.Context) {
+	last6hoursByPrice(c, NoComputeUnitPrice)
+}
+
+func last6hoursAll(c *gin.Context) {
+	last6hoursByPrice(c, AllData)
+}
+
+// last6hoursByPrice return last 6 hours statistic of priceType data. min_price/max_price/price_tier/fee_strategy query further filter the data
+func last6hoursByPrice(c *gin.Context, priceType ComputeUnitPriceType) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
+	filter := PingResultFilter{Cluster: cluster, PingType: DataPoint1Min, PriceType: priceType}
+	if err := parseFeeRangeParams(c, &filter); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ret, err := GetLast6hours(c.Request.Context(), filter)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.IndentedJSON(http.StatusOK, ret)
 }
 
-func last6hoursNoPrice(c *gin.Context) {
-	cluster := c.Param("cluster")
-	var ret []DataPoint1MinResultJSON
-	switch cluster {
-	case "mainnet-beta":
-		ret = GetLast6hours(MainnetBeta, NoComputeUnitPrice, 0)
-	case "testnet":
-		ret = GetLast6hours(Testnet, NoComputeUnitPrice, 0)
-	case "devnet":
-		ret = GetLast6hours(Devnet, NoComputeUnitPrice, 0)
-	default:
+// timeRange return the statistic of [from, to] grouped by step. from/to accept unix seconds or RFC3339. default is last 6 hours with 1m step.
+func timeRange(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
+	from, to, step, err := parseRangeParams(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseFeeFilterParams(c, cluster, HasComputeUnitPrice)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	ret, err := GetRange(c.Request.Context(), filter, step)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.IndentedJSON(http.StatusOK, ret)
 }
 
-func last6hoursAll(c *gin.Context) {
-	cluster := c.Param("cluster")
-	var ret []DataPoint1MinResultJSON
-	switch cluster {
-	case "mainnet-beta":
-		ret = GetLast6hours(MainnetBeta, AllData, 0)
-	case "testnet":
-		ret = GetLast6hours(Testnet, AllData, 0)
-	case "devnet":
-		ret = GetLast6hours(Devnet, AllData, 0)
-	default:
-		c.AbortWithStatus(http.StatusNotFound)
+// parseRangeParams parse from/to/step query of a range request. default is last 6 hours with 1m step
+func parseRangeParams(c *gin.Context) (from int64, to int64, step int64, err error) {
+	from, to, err = parseTimeRangeParams(c)
+	if err != nil {
 		return
 	}
-	c.IndentedJSON(http.StatusOK, ret)
+	step, err = parseStepParam(c.DefaultQuery("step", "1m"))
+	if err != nil {
+		return
+	}
+	if (to-from)/step > MaxRangeGroups {
+		err = ErrTooManyGroups
+		return
+	}
+	if to-from > MaxAggregateRange {
+		err = ErrRangeTooLong
+	}
+	return
+}
+
+// clusterFromParam convert the cluster name in url to Cluster
+func clusterFromParam(cluster string) (Cluster, bool) {
+	if c, ok := findClusterByRoute(cluster); ok {
+		return c, true
+	}
+	log.Println("StatusNotFound Error:", cluster)
+	return "", false
+}
+
+// findClusterByRoute return the cluster whose url name is route
+func findClusterByRoute(route string) (Cluster, bool) {
+	for _, cConf := range config.Clusters {
+		if cConf.Route == route {
+			return cConf.Cluster, true
+		}
+	}
+	return "", false
+}
+
+// clusterRouteName is the cluster name used in url
+func clusterRouteName(c Cluster) string {
+	if cConf, ok := findClusterConfig(c); ok {
+		return cConf.Route
+	}
+	return string(c)
+}
+
+// parseTimeParam parse unix seconds or RFC3339 time. Return defaultTime if t is empty
+func parseTimeParam(t string, defaultTime int64) (int64, error) {
+	if len(t) == 0 {
+		return defaultTime, nil
+	}
+	if ts, err := strconv.ParseInt(t, 10, 64); err == nil {
+		return ts, nil
+	}
+	ts, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return 0, ErrInvalidTimeFormat
+	}
+	return ts.UTC().Unix(), nil
+}
+
+// parseTimeRangeParams parse from/to query. default is last 6 hours
+func parseTimeRangeParams(c *gin.Context) (from int64, to int64, err error) {
+	to, err = parseTimeParam(c.Query("to"), time.Now().UTC().Unix())
+	if err != nil {
+		return
+	}
+	from, err = parseTimeParam(c.Query("from"), to-6*60*60)
+	if err != nil {
+		return
+	}
+	if from >= to {
+		err = ErrInvalidTimeRange
+	}
+	return
+}
+
+// parsePriceParams parse price and threshold query. threshold is required when price is threshold
+func parsePriceParams(c *gin.Context, defaultPriceType ComputeUnitPriceType) (ComputeUnitPriceType, uint64, error) {
+	priceType := ComputeUnitPriceType(c.DefaultQuery("price", string(defaultPriceType)))
+	switch priceType {
+	case AllData, NoComputeUnitPrice, HasComputeUnitPrice:
+		return priceType, 0, nil
+	case ComputeUnitPriceThreshold:
+		threshold, err := strconv.ParseUint(c.Query("threshold"), 10, 64)
+		if err != nil {
+			return priceType, 0, ErrInvalidThreshold
+		}
+		return priceType, threshold, nil
+	}
+	return priceType, 0, ErrInvalidPriceType
+}
+
+// parseFeeFilterParams parse price/threshold and min_price/max_price/price_tier/fee_strategy query into a DataPoint1Min filter of the cluster
+func parseFeeFilterParams(c *gin.Context, cluster Cluster, defaultPriceType ComputeUnitPriceType) (PingResultFilter, error) {
+	filter := PingResultFilter{Cluster: cluster, PingType: DataPoint1Min}
+	var err error
+	filter.PriceType, filter.Threshold, err = parsePriceParams(c, defaultPriceType)
+	if err != nil {
+		return filter, err
+	}
+	err = parseFeeRangeParams(c, &filter)
+	return filter, err
+}
+
+// parseFeeRangeParams parse min_price/max_price/price_tier/fee_strategy query into filter
+func parseFeeRangeParams(c *gin.Context, filter *PingResultFilter) error {
+	parse := func(name string) (*uint64, error) {
+		v, ok := c.GetQuery(name)
+		if !ok {
+			return nil, nil
+		}
+		price, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidPriceFilter
+		}
+		return &price, nil
+	}
+	var err error
+	if filter.MinPrice, err = parse("min_price"); err != nil {
+		return err
+	}
+	if filter.MaxPrice, err = parse("max_price"); err != nil {
+		return err
+	}
+	if filter.PriceTier, err = parse("price_tier"); err != nil {
+		return err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return ErrInvalidPriceFilter
+	}
+	if strategy, ok := c.GetQuery("fee_strategy"); ok {
+		switch FeeStrategyType(strategy) {
+		case MaxFee, FixedFee, PercentileFee, MedianMultiplierFee, InfluxFee, LadderFee:
+			filter.FeeStrategy = strategy
+		default:
+			return ErrInvalidFeeStrategyParam
+		}
+	}
+	return nil
+}
+
+// parseStepParam convert a step string to seconds
+func parseStepParam(step string) (int64, error) {
+	switch step {
+	case "1m":
+		return 60, nil
+	case "5m":
+		return 5 * 60, nil
+	case "15m":
+		return 15 * 60, nil
+	case "1h":
+		return 60 * 60, nil
+	}
+	return 0, ErrInvalidStep
 }
 
 // GetLatestResult return the latest DataPoint1Min PingResult from the cluster and convert it into PingResultJSON
@@ -216,56 +386,67 @@ func GetLatestResult(c Cluster) DataPoint1MinResultJSON {
 	return DataPoint1MinResultJSON{}
 }
 
-// GetLast6hours return the latest 6hr DataPoint1Min PingResult from the cluster and convert it into PingResultJSON
-func GetLast6hours(c Cluster, priceType ComputeUnitPriceType, threshold uint64) []DataPoint1MinResultJSON {
-	lastRecord := getLastN(c, DataPoint1Min, 1, priceType, 0)
-	now := time.Now().UTC().Unix()
-	if len(lastRecord) > 0 {
-		if (now - lastRecord[0].TimeStamp) < 60 { // in past one min, there is a record, use it
-			now = lastRecord[0].TimeStamp
+// GetLast6hours return the latest 6hr DataPoint1Min PingResult matched the filter and convert it into PingResultJSON. From/To of the filter are ignored.
+func GetLast6hours(ctx context.Context, f PingResultFilter) ([]DataPoint1MinResultJSON, error) {
+	if !f.HasFeeFilter() {
+		if stats, ok := historyCache.Last6hours(f.Cluster, f.PriceType); ok {
+			ret := []DataPoint1MinResultJSON{}
+			for i := range stats {
+				ret = append(ret, PingResultToJson(&stats[i]))
+			}
+			return ret, nil
 		}
 	}
-
-	// (-1) because getAfter function return only after .
-	beginOfPast60Hours := now - 6*60*60
-	var records []PingResult
-	switch priceType {
-	case NoComputeUnitPrice:
-		records = getAfter(c, DataPoint1Min, beginOfPast60Hours, NoComputeUnitPrice, 0)
-	case HasComputeUnitPrice:
-		records = getAfter(c, DataPoint1Min, beginOfPast60Hours, HasComputeUnitPrice, 0)
-	case ComputeUnitPriceThreshold:
-		records = getAfter(c, DataPoint1Min, beginOfPast60Hours, ComputeUnitPriceThreshold, threshold)
-	case AllData:
-		fallthrough
-	default:
-		records = getAfter(c, DataPoint1Min, beginOfPast60Hours, AllData, 0)
-	}
-
-	if len(records) == 0 {
-		return []DataPoint1MinResultJSON{}
+	lastRecord := getLastN(f.Cluster, DataPoint1Min, 1, f.PriceType, 0)
+	latest := int64(0)
+	if len(lastRecord) > 0 {
+		latest = lastRecord[0].TimeStamp
 	}
-	groups := grouping1Min(records, beginOfPast60Hours, now)
-	if len(groups) != 6*60 {
-		log.Println("WARN! groups is not 360!", " beginOfPast60Hours:", beginOfPast60Hours, "now")
+	end := last6hoursEnd(latest, time.Now().UTC().Unix())
+	f.From, f.To = end-6*60*60, end
+	ret, err := GetRange(ctx, f, 60)
+	if len(ret) != 0 && len(ret) != 6*60 {
+		log.Println("WARN! groups is not 360!", " beginOfPast60Hours:", f.From, "now")
 	}
+	return ret, err
+}
 
-	groupsStat := statisticCompute(GetClusterConfig(c), groups)
+// GetRange return PingResult matched the filter grouped by step seconds and convert it into PingResultJSON.
+// The fee distribution of each group is returned only when the filter has a fee filter.
+func GetRange(ctx context.Context, f PingResultFilter, step int64) ([]DataPoint1MinResultJSON, error) {
 	ret := []DataPoint1MinResultJSON{}
+	groupsStat, err := getRangeStatistic(ctx, f, step)
+	if err != nil || groupsStat == nil {
+		return ret, err
+	}
 	for _, g := range groupsStat.PingStatisticList {
-		ret = append(ret, PingResultToJson(&g))
+		point := PingResultToJson(&g)
+		if f.HasFeeFilter() {
+			point.FeeDistribution = g.FeeDistribution
+		}
+		ret = append(ret, point)
 	}
-	return ret
+	return ret, nil
+}
+
+// getRangeStatistic return the statistic of PingResult matched the filter grouped by step seconds. Return nil if there is no data.
+// Rows are streamed from the database so only the rows of one group are in memory.
+func getRangeStatistic(ctx context.Context, f PingResultFilter, step int64) (*GroupsAllStatistic, error) {
+	return statisticComputeStream(GetClusterConfig(f.Cluster), f.From, f.To, step, func(fn func(*PingResult) error) error {
+		return forEachPingResult(ctx, f, fn)
+	})
 }
 
 func GetClusterConfig(c Cluster) ClusterConfig {
-	switch c {
-	case MainnetBeta:
-		return config.Mainnet
-	case Testnet:
-		return config.Testnet
-	case Devnet:
-		return config.Devnet
-	}
-	return ClusterConfig{}
+	cConf, _ := findClusterConfig(c)
+	return cConf
+}
+
+func findClusterConfig(c Cluster) (ClusterConfig, bool) {
+	for _, cConf := range config.Clusters {
+		if cConf.Cluster == c {
+			return cConf, true
+		}
+	}
+	return ClusterConfig{}, false
 }
@@ -0,0 +1,258 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/timeout"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi-v2.json
+var openAPIV2Spec []byte
+
+// LatencyV2JSON is the confirmation take-time statistic in ms
+type LatencyV2JSON struct {
+	Min    int64   `json:"min"`
+	Mean   float64 `json:"mean"`
+	Max    int64   `json:"max"`
+	Stddev float64 `json:"stddev"`
+	P50    int64   `json:"p50"`
+	P90    int64   `json:"p90"`
+	P99    int64   `json:"p99"`
+}
+
+// ComputeUnitPriceV2JSON is the compute unit price (micro lamports) of the samples
+type ComputeUnitPriceV2JSON struct {
+	Min  uint64  `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  uint64  `json:"max"`
+}
+
+// DataPointV2JSON is the v2 output of a group of PingResult. All numbers are typed.
+type DataPointV2JSON struct {
+	TimeStamp        string                   `json:"ts"`
+	TimeStampUnix    int64                    `json:"ts_unix"`
+	WindowSec        int64                    `json:"window_sec"`
+	Count            int                      `json:"count"`
+	Submitted        int                      `json:"submitted"`
+	Confirmed        int                      `json:"confirmed"`
+	Loss             float64                  `json:"loss"` // 0 ~ 1
+	LatencyMs        LatencyV2JSON            `json:"latency_ms"`
+	ComputeUnitPrice ComputeUnitPriceV2JSON   `json:"compute_unit_price"`
+	FeeDistribution  map[uint64]int           `json:"fee_distribution"` // compute unit price -> count of samples
+	ErrorCount       int                      `json:"error_count"`
+	Errors           map[string]int           `json:"errors"`
+	StagesMs         map[string]LatencyV2JSON `json:"stages_ms"` // stage name -> take time. stages without data are omitted
+	SlotLatency      SlotLatencyV2JSON        `json:"slot_latency"`
+}
+
+// SlotLatencyV2JSON is the mean slot deltas of landed txs. Means are null if no landed slot is known
+type SlotLatencyV2JSON struct {
+	Count              int      `json:"count"`
+	SinceSendMean      *float64 `json:"since_send_mean"`      // landed slot - processed slot before sending
+	SinceBlockhashMean *float64 `json:"since_blockhash_mean"` // landed slot - slot of the blockhash
+}
+
+func slotLatencyV2Json(m SlotMeasure) SlotLatencyV2JSON {
+	ret := SlotLatencyV2JSON{Count: m.Count}
+	if m.Count > 0 {
+		send, blockhash := m.Mean()
+		ret.SinceSendMean, ret.SinceBlockhashMean = &send, &blockhash
+	}
+	return ret
+}
+
+func registerV2Routes(router *gin.Engine) {
+	v2 := router.Group("/v2")
+	v2.GET("/openapi.json", openAPIV2)
+	v2.GET("/:cluster/latest", getLatestV2)
+	v2.GET("/:cluster/range", timeout.New(timeout.WithTimeout(30*time.Second), timeout.WithHandler(timeRangeV2)))
+}
+
+// openAPIV2 serve the OpenAPI document whose cluster parameter is one of the configured clusters
+func openAPIV2(c *gin.Context) {
+	routes := []string{}
+	for _, cConf := range config.Clusters {
+		routes = append(routes, cConf.Route)
+	}
+	doc, err := openAPIV2Document(routes)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/json", doc)
+}
+
+// openAPIV2Document set routes as the enum of the cluster parameter of openAPIV2Spec
+func openAPIV2Document(routes []string) ([]byte, error) {
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(openAPIV2Spec, &spec); err != nil {
+		return nil, err
+	}
+	components, _ := spec["components"].(map[string]interface{})
+	parameters, _ := components["parameters"].(map[string]interface{})
+	cluster, _ := parameters["Cluster"].(map[string]interface{})
+	schema, _ := cluster["schema"].(map[string]interface{})
+	if schema == nil {
+		return nil, ErrInvalidOpenAPISpec
+	}
+	schema["enum"] = routes
+	return json.Marshal(spec)
+}
+
+func getLatestV2(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrInvalidCluster.Error()})
+		return
+	}
+	records := getLastN(cluster, DataPoint1Min, 1, HasComputeUnitPrice, 0)
+	if len(records) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrNoPingResultRecord.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, PingResultToV2Json(&records[0]))
+}
+
+// timeRangeV2 is the v2 version of timeRange. price query selects all/zero/hasprice/threshold data. default is hasprice.
+// min_price/max_price/price_tier/fee_strategy query further filter the data
+func timeRangeV2(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrInvalidCluster.Error()})
+		return
+	}
+	from, to, step, err := parseRangeParams(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseFeeFilterParams(c, cluster, HasComputeUnitPrice)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	ret := []DataPointV2JSON{}
+	groupsStat, err := getRangeStatistic(c.Request.Context(), filter, step)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if groupsStat != nil {
+		for _, g := range groupsStat.PingStatisticList {
+			ret = append(ret, PingStatisticToV2Json(&g))
+		}
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// PingStatisticToV2Json convert PingSatistic to DataPointV2JSON
+func PingStatisticToV2Json(stat *PingSatistic) DataPointV2JSON {
+	ts := time.Unix(stat.TimeStamp, 0).UTC()
+	ret := DataPointV2JSON{
+		TimeStamp:       ts.Format(time.RFC3339),
+		TimeStampUnix:   stat.TimeStamp,
+		WindowSec:       stat.Window,
+		Count:           stat.Count,
+		Submitted:       int(stat.Submitted),
+		Confirmed:       int(stat.Confirmed),
+		Loss:            stat.Loss,
+		LatencyMs:       latencyV2Json(stat.TimeStatistic),
+		FeeDistribution: stat.FeeDistribution,
+		ErrorCount:      len(stat.Errors),
+		Errors:          errorCategoryCount(stat.Errors),
+		StagesMs:        map[string]LatencyV2JSON{},
+		SlotLatency:     slotLatencyV2Json(stat.SlotMeasure),
+	}
+	for i, t := range stat.StageMeasure.TimeStatistic() {
+		if t.Mean > 0 {
+			ret.StagesMs[PingStageNames[i]] = latencyV2Json(t)
+		}
+	}
+	if stat.Count == 0 { // no data
+		ret.Loss = 0
+	}
+	var sum float64
+	var count int
+	for price, n := range stat.FeeDistribution {
+		if count == 0 || price < ret.ComputeUnitPrice.Min {
+			ret.ComputeUnitPrice.Min = price
+		}
+		if price > ret.ComputeUnitPrice.Max {
+			ret.ComputeUnitPrice.Max = price
+		}
+		sum += float64(price) * float64(n)
+		count += n
+	}
+	if count > 0 {
+		ret.ComputeUnitPrice.Mean = sum / float64(count)
+	}
+	return ret
+}
+
+// PingResultToV2Json convert a single PingResult to DataPointV2JSON
+func PingResultToV2Json(r *PingResult) DataPointV2JSON {
+	ts := time.Unix(r.TimeStamp, 0).UTC()
+	ret := DataPointV2JSON{
+		TimeStamp:     ts.Format(time.RFC3339),
+		TimeStampUnix: r.TimeStamp,
+		Count:         1,
+		Submitted:     r.Submitted,
+		Confirmed:     r.Confirmed,
+		LatencyMs: LatencyV2JSON{
+			Min:    r.Min,
+			Mean:   float64(r.Mean),
+			Max:    r.Max,
+			Stddev: float64(r.Stddev),
+			P50:    r.P50,
+			P90:    r.P90,
+			P99:    r.P99,
+		},
+		ComputeUnitPrice: ComputeUnitPriceV2JSON{
+			Min:  r.ComputeUnitPrice,
+			Mean: float64(r.ComputeUnitPrice),
+			Max:  r.ComputeUnitPrice,
+		},
+		FeeDistribution: map[uint64]int{r.ComputeUnitPrice: 1},
+		ErrorCount:      len(r.Error),
+		Errors:          errorCategoryCount(r.Error),
+		StagesMs:        map[string]LatencyV2JSON{},
+	}
+	slots := SlotMeasure{}
+	slots.AddResult(r)
+	ret.SlotLatency = slotLatencyV2Json(slots)
+	for i, t := range r.Stages() {
+		if t > 0 {
+			ret.StagesMs[PingStageNames[i]] = LatencyV2JSON{Min: t, Mean: float64(t), Max: t, P50: t, P90: t, P99: t}
+		}
+	}
+	if r.Submitted > 0 {
+		ret.Loss = float64(r.Submitted-r.Confirmed) / float64(r.Submitted)
+	}
+	return ret
+}
+
+func latencyV2Json(t TimeStatistic) LatencyV2JSON {
+	return LatencyV2JSON{
+		Min:    t.Min,
+		Mean:   t.Mean,
+		Max:    t.Max,
+		Stddev: t.Stddev,
+		P50:    t.P50,
+		P90:    t.P90,
+		P99:    t.P99,
+	}
+}
+
+// errorCategoryCount count errors by PingResultError.Category
+func errorCategoryCount(errs []string) map[string]int {
+	ret := map[string]int{}
+	for _, e := range errs {
+		ret[PingResultError(e).Category()]++
+	}
+	return ret
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certReloadDelay wait for writes of the certificate and key files to finish before reloading
+const certReloadDelay = time.Second
+
+// certReloader serve a certificate from files and reload it when the files change or on SIGHUP
+type certReloader struct {
+	crtPath string
+	keyPath string
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+}
+
+var certReloaders struct {
+	sync.Mutex
+	list []*certReloader
+}
+
+func newCertReloader(crtPath string, keyPath string) (*certReloader, error) {
+	r := &certReloader{crtPath: crtPath, keyPath: keyPath}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	certReloaders.Lock()
+	certReloaders.list = append(certReloaders.list, r)
+	certReloaders.Unlock()
+	return r, nil
+}
+
+// Reload load the certificate and key files. The current certificate is kept if loading fails
+func (r *certReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.crtPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	r.cert = &cert
+	r.mutex.Unlock()
+	return nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// watch reload the certificate when files in the directories of the certificate and key change.
+// Directories are watched so that files replaced by rename or symlink swap are detected.
+func (r *certReloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{filepath.Dir(r.crtPath), filepath.Dir(r.keyPath)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	go func() {
+		defer watcher.Close()
+		var reload <-chan time.Time
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				reload = time.After(certReloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("certificate watcher error:", err)
+			case <-reload:
+				reload = nil
+				if err := r.Reload(); err != nil {
+					log.Println("reload certificate ", r.crtPath, " error:", err)
+				} else {
+					log.Println("certificate ", r.crtPath, " reloaded")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// reloadCertificates reload all certificates loaded from files. It is called on SIGHUP
+func reloadCertificates() {
+	certReloaders.Lock()
+	defer certReloaders.Unlock()
+	for _, r := range certReloaders.list {
+		if err := r.Reload(); err != nil {
+			log.Println("reload certificate ", r.crtPath, " error:", err)
+			continue
+		}
+		log.Println("certificate ", r.crtPath, " reloaded")
+	}
+}
+
+// newServerTLSConfig create the tls config of an api server. Certificates are issued by ACME if ACME is enabled,
+// otherwise they are loaded from CrtPath/KeyPath and reloaded when the files change.
+// The returned autocert.Manager is nil if ACME is not enabled.
+func newServerTLSConfig(conf APIServer) (*tls.Config, *autocert.Manager, error) {
+	if conf.ACME.Enabled {
+		m, err := newACMEManager(conf.ACME)
+		if err != nil {
+			return nil, nil, err
+		}
+		return m.TLSConfig(), m, nil
+	}
+	r, err := newCertReloader(conf.CrtPath, conf.KeyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.watch(); err != nil { // SIGHUP still works
+		log.Println("watch certificate ", conf.CrtPath, " error:", err)
+	}
+	return &tls.Config{GetCertificate: r.GetCertificate}, nil, nil
+}
+
+func newACMEManager(conf ACMEConfig) (*autocert.Manager, error) {
+	if len(conf.Domains) == 0 {
+		return nil, ErrNoACMEDomain
+	}
+	cacheDir := conf.CacheDir
+	if len(cacheDir) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		cacheDir = filepath.Join(home, ".config", "ping-api", "acme")
+	}
+	client := &acme.Client{DirectoryURL: conf.DirectoryURL}
+	if len(client.DirectoryURL) == 0 {
+		client.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+	if len(conf.DirectoryCAPath) > 0 { // trust the CA of a test ACME server
+		pem, err := os.ReadFile(conf.DirectoryCAPath)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate in " + conf.DirectoryCAPath)
+		}
+		client.HTTPClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(conf.Domains...),
+		Email:      conf.Email,
+		Client:     client,
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/blocto/solana-go-sdk/rpc"
 	// jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 )
@@ -29,8 +30,44 @@ type PingConfig struct {
 	StatusCheckInterval     int64
 	MinPerPingTime          int64
 	ComputeFeeDualMode      bool
+	FeeLadder               []uint64 // compute unit prices cycled across workers. 0 is no fee. replace ComputeFeeDualMode and FeeStrategy
 	RequestUnits            uint32
-	ComputeUnitPrice        uint64
+	ComputeUnitPrice        uint64 // the price of fixed FeeStrategy. 0 disables the compute unit price
+	FeeStrategy             FeeStrategyConfig
+	ConfirmationMode        string // polling or websocket. default is polling
+	TrackFinalized          bool   // keep checking confirmed txs until finalized to measure the finalized stage
+	TxType                  string // transfer, memo, spl-token or custom. default is transfer
+	SPLToken                SPLTokenConfig
+	CustomInstruction       CustomInstructionConfig
+}
+
+// FeeStrategyConfig decide the compute unit price of ping txs
+type FeeStrategyConfig struct {
+	Type        string  // max, fixed, percentile, median-multiplier or influx. default is max
+	Percentile  float64 // percentile: (0, 100]
+	Multiplier  float64 // median-multiplier
+	InfluxQuery string  // influx: flux query whose first value is the price. run on the server of InfluxdbConfig
+	Cap         uint64  // upper bound of the price. default is DefaultFeeCap
+}
+
+// SPLTokenConfig is the token of spl-token TxType. Tokens are sent from the associated token account of the
+// fee payer to the associated token account of Receiver
+type SPLTokenConfig struct {
+	Mint     string
+	Decimals uint8
+}
+
+// CustomInstructionConfig is the instruction of custom TxType
+type CustomInstructionConfig struct {
+	ProgramID string
+	Accounts  []AccountMetaConfig
+	Data      string // base64
+}
+
+type AccountMetaConfig struct {
+	Pubkey     string // empty is the fee payer
+	IsSigner   bool   // only the fee payer can be a signer
+	IsWritable bool
 }
 type WebHookConfig struct {
 	Enabled bool
@@ -49,18 +86,57 @@ type DiscordReport struct {
 type Report struct {
 	Enabled       bool
 	Interval      int
+	GroupWindow   int64 // seconds of each group in a report
 	LossThreshold float64
 	LevelFilePath string
 	Slack         SlackReport
 	Discord       DiscordReport
 }
 type APIServer struct {
-	Enabled bool
-	Mode    ConnectionMode
-	IP      string
-	SSLIP   string
-	KeyPath string
-	CrtPath string
+	Enabled      bool
+	Mode         ConnectionMode
+	IP           string
+	SSLIP        string
+	KeyPath      string
+	CrtPath      string
+	ACME         ACMEConfig
+	Auth         APIAuth
+	Availability Availability
+}
+
+// ACMEConfig issue certificates of Domains by ACME instead of CrtPath/KeyPath.
+// DirectoryURL is Let's Encrypt if empty. DirectoryCAPath is the CA to trust for a test ACME server.
+type ACMEConfig struct {
+	Enabled         bool
+	DirectoryURL    string
+	DirectoryCAPath string
+	Domains         []string
+	Email           string
+	CacheDir        string // default is ~/.config/ping-api/acme
+}
+
+// Availability is the default SLO of the availability endpoint
+type Availability struct {
+	LossThreshold float64 // percentage
+	LatencySLO    int64   // ms of mean confirmation time
+}
+
+// APIAuth api key authentication. RateLimit is requests per second. 0 means no limit
+type APIAuth struct {
+	Enabled         bool
+	PublicAccess    bool // allow requests without api key
+	PublicRateLimit float64
+	PublicBurst     int
+	TrustedProxies  []string // ips or cidrs of reverse proxies whose X-Forwarded-For is trusted. empty trusts none
+	Keys            []APIKey
+}
+type APIKey struct {
+	Name       string
+	Key        string
+	RateLimit  float64
+	Burst      int
+	DailyQuota int64 // requests per UTC day. 0 means no quota
+	Admin      bool  // allow admin actions
 }
 type Database struct {
 	UseGoogleCloud       bool
@@ -89,13 +165,18 @@ type SolanaCLIConfig struct {
 }
 
 type ClusterCLIConfig struct {
-	Dir           string
-	MainnetPath   string
-	TestnetPath   string
-	DevnetPath    string
-	ConfigMain    SolanaCLIConfig
-	ConfigTestnet SolanaCLIConfig
-	ConfigDevnet  SolanaCLIConfig
+	Dir         string
+	MainnetPath string
+	TestnetPath string
+	DevnetPath  string
+}
+
+// ClusterDefinition is a cluster defined in Clusters of config.yaml
+type ClusterDefinition struct {
+	Name          string // name of the cluster in database and CLI argument
+	Route         string // name of the cluster in url. default is Name
+	ConfigFile    string // cluster config file in ~/.config/ping-api without .yaml
+	SolanaCliFile string // solana cli config file in SolanaCliFile.Dir
 }
 
 type RPCEndpoint struct {
@@ -124,16 +205,17 @@ type ClusterPing struct {
 
 type ClusterConfig struct {
 	Cluster
-	HostName string
+	Name      string // name in CLI argument
+	Route     string // name in url
+	HostName  string
+	CLIConfig SolanaCLIConfig
 	ClusterPing
 }
 
 type Config struct {
 	Database
 	InfluxdbConfig
-	Mainnet ClusterConfig
-	Testnet ClusterConfig
-	Devnet  ClusterConfig
+	Clusters []ClusterConfig // MainnetBeta, Testnet, Devnet and clusters in Clusters of config.yaml
 	ClusterCLIConfig
 	Retension
 }
@@ -142,15 +224,12 @@ func loadConfig() Config {
 	// jww.SetLogThreshold(jww.LevelTrace)
 	// jww.SetStdoutThreshold(jww.LevelTrace)
 	c := Config{}
-	v := viper.New()
 	userHome, err := os.UserHomeDir()
 	if err != nil {
 		panic("loadConfig error:" + err.Error())
 	}
-
-	v.AddConfigPath(userHome + "/.config/ping-api")
-	v.SetConfigType("yaml")
-	v.AutomaticEnv()
+	configDir := userHome + "/.config/ping-api"
+	v := newConfigViper(configDir)
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = "unknown"
@@ -189,70 +268,95 @@ func loadConfig() Config {
 		DevnetPath:  v.GetString("SolanaCliFile.DevnetPath"),
 	}
 
-	if len(c.ClusterCLIConfig.MainnetPath) > 0 {
-		sConfig, err := ReadSolanaCLIConfigFile(c.ClusterCLIConfig.Dir + c.ClusterCLIConfig.MainnetPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		c.ClusterCLIConfig.ConfigMain = sConfig
-	}
-	if len(c.ClusterCLIConfig.TestnetPath) > 0 {
-		sConfig, err := ReadSolanaCLIConfigFile(c.ClusterCLIConfig.Dir + c.ClusterCLIConfig.TestnetPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		c.ClusterCLIConfig.ConfigTestnet = sConfig
-	}
-	if len(c.ClusterCLIConfig.DevnetPath) > 0 {
-		sConfig, err := ReadSolanaCLIConfigFile(c.ClusterCLIConfig.Dir + c.ClusterCLIConfig.DevnetPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		c.ClusterCLIConfig.ConfigDevnet = sConfig
+	definitions := []ClusterDefinition{}
+	if err := v.UnmarshalKey("Clusters", &definitions); err != nil {
+		log.Fatal("Clusters config error:", err)
 	}
 	// setup  config.yaml (SolanaCliFile) all cluster services
 	configMainnetFile := v.GetString("ClusterConfigFile.Mainnet")
 	configTestnetFile := v.GetString("ClusterConfigFile.Testnet")
 	configDevnetFile := v.GetString("ClusterConfigFile.Devnet")
 	// Read Each Cluster Configurations
-	// setup config.yaml for mainnet
-	v.SetConfigName(configMainnetFile)
-	v.ReadInConfig()
-	c.Mainnet = ClusterConfig{
-		Cluster:     MainnetBeta,
-		HostName:    hostname,
-		ClusterPing: ReadClusterPingConfig(v),
+	c.Clusters = []ClusterConfig{
+		readClusterConfig(configDir, c.ClusterCLIConfig.Dir, MainnetBeta, string(RunMainnetBeta), "mainnet-beta", configMainnetFile, c.ClusterCLIConfig.MainnetPath),
+		readClusterConfig(configDir, c.ClusterCLIConfig.Dir, Testnet, string(RunTestnet), "testnet", configTestnetFile, c.ClusterCLIConfig.TestnetPath),
+		readClusterConfig(configDir, c.ClusterCLIConfig.Dir, Devnet, string(RunDevnet), "devnet", configDevnetFile, c.ClusterCLIConfig.DevnetPath),
 	}
-	if c.Mainnet.APIServer.Mode != HTTP &&
-		c.Mainnet.APIServer.Mode != HTTPS && c.Mainnet.APIServer.Mode != BOTH {
-		c.Mainnet.APIServer.Mode = HTTP
-		log.Println("Mainnet API server mode not support! use default mode")
+	for _, d := range definitions {
+		if len(d.Name) == 0 || len(d.ConfigFile) == 0 {
+			log.Fatal("Clusters config error: Name and ConfigFile are required. ", d)
+		}
+		if len(d.Route) == 0 {
+			d.Route = d.Name
+		}
+		c.Clusters = append(c.Clusters, readClusterConfig(configDir, c.ClusterCLIConfig.Dir, Cluster(d.Name), d.Name, d.Route, d.ConfigFile, d.SolanaCliFile))
 	}
-	v.SetConfigName(configTestnetFile)
-	v.ReadInConfig()
-	c.Testnet = ClusterConfig{
-		Cluster:     Testnet,
-		HostName:    hostname,
-		ClusterPing: ReadClusterPingConfig(v),
+	names, routes := map[string]bool{}, map[string]bool{}
+	for i := range c.Clusters {
+		cConf := &c.Clusters[i]
+		if names[cConf.Name] || names[string(cConf.Cluster)] || routes[cConf.Route] || cConf.Name == string(RunAllClusters) {
+			log.Fatal("Clusters config error: duplicated cluster ", cConf.Name, " route ", cConf.Route)
+		}
+		names[cConf.Name], names[string(cConf.Cluster)], routes[cConf.Route] = true, true, true
+		cConf.HostName = hostname
 	}
-	if c.Testnet.APIServer.Mode != HTTP &&
-		c.Testnet.APIServer.Mode != HTTPS && c.Testnet.APIServer.Mode != BOTH {
-		c.Testnet.APIServer.Mode = HTTP
-		log.Println("Mainnet API server mode not support! use default mode")
+	return c
+}
+
+// ComputeFeeEnabled tell whether txs can be sent with a compute unit price
+func (c PingConfig) ComputeFeeEnabled() bool {
+	return c.ComputeUnitPrice > 0 || len(c.FeeLadder) > 0
+}
+
+// DefaultRPCEndpoint is the rpc endpoint of the cluster when it has no AlternativeEnpoint.
+// A config-defined cluster uses json_rpc_url of its solana cli config
+func (c ClusterConfig) DefaultRPCEndpoint() string {
+	switch c.Cluster {
+	case MainnetBeta:
+		return rpc.MainnetRPCEndpoint
+	case Testnet:
+		return rpc.TestnetRPCEndpoint
+	case Devnet:
+		return rpc.DevnetRPCEndpoint
 	}
-	v.SetConfigName(configDevnetFile)
-	v.ReadInConfig()
-	c.Devnet = ClusterConfig{
-		Cluster:     Devnet,
-		HostName:    hostname,
-		ClusterPing: ReadClusterPingConfig(v),
+	return c.CLIConfig.JsonRPCURL
+}
+
+// newConfigViper create a viper which reads yaml files in dir
+func newConfigViper(dir string) *viper.Viper {
+	v := viper.New()
+	v.AddConfigPath(dir)
+	v.SetConfigType("yaml")
+	v.AutomaticEnv()
+	return v
+}
+
+// readClusterConfig read the cluster config file and the solana cli config file of a cluster.
+// Each cluster has its own viper, so a cluster never gets settings of another. A built-in cluster
+// without configFile has the default settings. It exits if configFile can not be read.
+func readClusterConfig(configDir string, cliDir string, cluster Cluster, name string, route string, configFile string, cliFile string) ClusterConfig {
+	cConf := ClusterConfig{Cluster: cluster, Name: name, Route: route}
+	if len(cliFile) > 0 {
+		sConfig, err := ReadSolanaCLIConfigFile(cliDir + cliFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cConf.CLIConfig = sConfig
+	}
+	v := newConfigViper(configDir)
+	if len(configFile) > 0 {
+		v.SetConfigName(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			log.Fatal(name, " config file ", configFile, " error:", err)
+		}
 	}
-	if c.Devnet.APIServer.Mode != HTTP &&
-		c.Devnet.APIServer.Mode != HTTPS && c.Devnet.APIServer.Mode != BOTH {
-		c.Devnet.APIServer.Mode = HTTP
-		log.Println("Devnet API server mode not support! use default mode")
+	cConf.ClusterPing = ReadClusterPingConfig(v)
+	if cConf.APIServer.Mode != HTTP &&
+		cConf.APIServer.Mode != HTTPS && cConf.APIServer.Mode != BOTH {
+		cConf.APIServer.Mode = HTTP
+		log.Println(cConf.Name, " API server mode not support! use default mode")
 	}
-	return c
+	return cConf
 }
 
 func ReadSolanaCLIConfigFile(filepath string) (SolanaCLIConfig, error) {
@@ -286,7 +390,7 @@ func ReadSolanaCLIConfigFile(filepath string) (SolanaCLIConfig, error) {
 	}
 	return SolanaCLIConfig{
 		JsonRPCURL:    configmap["json_rpc_url"],
-		WebsocketURL:  configmap["websocket_url:"],
+		WebsocketURL:  configmap["websocket_url"],
 		KeypairPath:   configmap["keypair_path"],
 		AddressLabels: addressmap,
 		Commitment:    configmap["commitment"],
@@ -309,5 +413,14 @@ func ReadClusterPingConfig(v *viper.Viper) ClusterPing {
 	v.Debug()
 	clusterConf := ClusterPing{}
 	v.Unmarshal(&clusterConf)
+	if clusterConf.Report.GroupWindow <= 0 {
+		clusterConf.Report.GroupWindow = DefaultGroupWindow
+	}
+	if clusterConf.APIServer.Availability.LossThreshold <= 0 {
+		clusterConf.APIServer.Availability.LossThreshold = DefaultAvailabilityLossThreshold
+	}
+	if clusterConf.APIServer.Availability.LatencySLO <= 0 {
+		clusterConf.APIServer.Availability.LatencySLO = DefaultAvailabilityLatencySLO
+	}
 	return clusterConf
 }
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dashboardChartWidth  = 720
+	dashboardChartHeight = 120
+	dashboardAlertCount  = 10
+	// dashboardCacheTTL is how long the statistic of a window which is not in historyCache is reused
+	dashboardCacheTTL = time.Minute
+)
+
+// dashboardWindow is the statistic of a chart window and the time range of its groups
+type dashboardWindow struct {
+	stats  []PingSatistic
+	from   int64
+	to     int64
+	expire time.Time
+}
+
+// dashboardWindowCache keep the statistic of windows for dashboardCacheTTL, so page views do not query the database
+type dashboardWindowCache struct {
+	mutex   sync.Mutex
+	windows map[string]dashboardWindow // cluster/hours -> window
+}
+
+var dashboardCache = dashboardWindowCache{windows: map[string]dashboardWindow{}}
+
+// DashboardChart is a pair of loss/latency chart of a time window
+type DashboardChart struct {
+	Title   string
+	Loss    template.HTML
+	Latency template.HTML
+}
+
+// DashboardAlert is an alert event for display
+type DashboardAlert struct {
+	Time      string
+	Trigger   string
+	Loss      string
+	Threshold string
+	Memo      string
+}
+
+// DashboardCluster is the status of a cluster
+type DashboardCluster struct {
+	Name        string
+	HasData     bool
+	LastUpdate  string
+	Loss        string
+	LossLevel   string
+	Latency     string
+	RPCEndpoint string
+	Charts      []DashboardChart
+	Alerts      []DashboardAlert
+}
+
+// DashboardPage is the data of the status page
+type DashboardPage struct {
+	Hostname string
+	Now      string
+	Clusters []DashboardCluster
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Solana Ping Status</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 24px; color: #222; background: #fafafa; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 18px; margin: 0 0 8px 0; }
+h3 { font-size: 14px; margin: 12px 0 4px 0; color: #555; }
+.sub { color: #777; font-size: 12px; }
+.cluster { background: #fff; border: 1px solid #ddd; border-radius: 6px; padding: 16px; margin: 16px 0; }
+.stats span { display: inline-block; margin-right: 24px; }
+.ok { color: #1a7f37; } .warn { color: #bf8700; } .bad { color: #cf222e; }
+svg { background: #fff; border: 1px solid #eee; display: block; margin-bottom: 4px; }
+table { border-collapse: collapse; font-size: 12px; }
+td, th { padding: 2px 8px; text-align: left; border-bottom: 1px solid #eee; }
+</style>
+</head>
+<body>
+<h1>Solana Ping Status</h1>
+<div class="sub">host: {{.Hostname}} &middot; updated: {{.Now}} &middot; refresh every 60s</div>
+{{range .Clusters}}
+<div class="cluster">
+<h2>{{.Name}}</h2>
+{{if .HasData}}
+<div class="stats">
+<span>loss: <b class="{{.LossLevel}}">{{.Loss}}</b></span>
+<span>confirmation: <b>{{.Latency}}</b></span>
+<span class="sub">last result: {{.LastUpdate}}</span>
+</div>
+{{else}}
+<div class="sub">no data</div>
+{{end}}
+<div class="sub">rpc endpoint: {{.RPCEndpoint}}</div>
+{{range .Charts}}
+<h3>{{.Title}}</h3>
+{{.Loss}}
+{{.Latency}}
+{{end}}
+<h3>recent alerts</h3>
+{{if .Alerts}}
+<table>
+<tr><th>time</th><th>trigger</th><th>loss</th><th>threshold</th><th>memo</th></tr>
+{{range .Alerts}}<tr><td>{{.Time}}</td><td>{{.Trigger}}</td><td>{{.Loss}}</td><td>{{.Threshold}}</td><td>{{.Memo}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<div class="sub">no alert</div>
+{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// dashboard render the status page of clusters as html. Charts are svg rendered in server side
+func dashboard(clusters []Cluster) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page := DashboardPage{Now: time.Now().UTC().Format(time.RFC3339)}
+		for _, cluster := range clusters {
+			cConf := GetClusterConfig(cluster)
+			page.Hostname = cConf.HostName
+			page.Clusters = append(page.Clusters, getDashboardCluster(c.Request.Context(), cluster))
+		}
+		var sb strings.Builder
+		if err := dashboardTemplate.Execute(&sb, page); err != nil {
+			log.Println("dashboard template error:", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(sb.String()))
+	}
+}
+
+func getDashboardCluster(ctx context.Context, cluster Cluster) DashboardCluster {
+	d := DashboardCluster{Name: clusterRouteName(cluster), RPCEndpoint: "-"}
+	if f := GetClusterFailover(cluster); f != nil && len(f.Endpoints) > 0 {
+		index, endpoints := f.Snapshot()
+		d.RPCEndpoint = endpoints[index].Endpoint // without access token
+	}
+	now := time.Now().UTC().Unix()
+	windows := []struct {
+		title string
+		hours int64
+		step  int64
+	}{{"last 6 hours", 6, 60}, {"last 24 hours", 24, 5 * 60}}
+	for _, w := range windows {
+		window := dashboardCache.get(ctx, cluster, w.hours, w.step)
+		if w.hours == 6 {
+			setDashboardCurrent(&d, window.stats)
+		}
+		d.Charts = append(d.Charts, DashboardChart{
+			Title:   w.title,
+			Loss:    svgLossChart(window.stats, window.from, window.to),
+			Latency: svgLatencyChart(window.stats, window.from, window.to),
+		})
+	}
+	for _, e := range getAlertEvents(cluster, 0, now, dashboardAlertCount) {
+		d.Alerts = append(d.Alerts, DashboardAlert{
+			Time:      time.Unix(e.TimeStamp, 0).UTC().Format(time.RFC3339),
+			Trigger:   e.Trigger,
+			Loss:      fmt.Sprintf("%3.1f%%", e.Loss),
+			Threshold: fmt.Sprintf("%3.0f%% -> %3.0f%%", e.OldThreshold, e.NewThreshold),
+			Memo:      e.Memo,
+		})
+	}
+	return d
+}
+
+// get return the statistic of the last hours of the cluster grouped by step seconds. The 6 hours window of 1m groups
+// is from historyCache if the cluster is cached. Other windows are queried at most once per dashboardCacheTTL.
+func (dc *dashboardWindowCache) get(ctx context.Context, cluster Cluster, hours int64, step int64) dashboardWindow {
+	if hours*60*60 == HistoryCacheWindow && step == 60 {
+		if stats, ok := historyCache.Last6hours(cluster, HasComputeUnitPrice); ok {
+			to := time.Now().UTC().Unix()
+			if len(stats) > 0 {
+				to = stats[0].TimeStamp
+			}
+			return dashboardWindow{stats: stats, from: to - HistoryCacheWindow, to: to}
+		}
+	}
+	key := fmt.Sprintf("%s/%d", cluster, hours)
+	// the lock is held while querying, so concurrent page views wait for one query instead of querying together
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	if w, ok := dc.windows[key]; ok && time.Now().Before(w.expire) {
+		return w
+	}
+	now := time.Now().UTC().Unix()
+	filter := PingResultFilter{Cluster: cluster, PingType: DataPoint1Min, PriceType: HasComputeUnitPrice, From: now - hours*60*60, To: now}
+	w := dashboardWindow{stats: []PingSatistic{}, from: filter.From, to: filter.To, expire: time.Now().Add(dashboardCacheTTL)}
+	groupsStat, err := getRangeStatistic(ctx, filter, step)
+	if err != nil {
+		log.Println("dashboard range statistic error:", err)
+		return w // not cached, the next view retries
+	}
+	if groupsStat != nil {
+		w.stats = groupsStat.PingStatisticList
+	}
+	dc.windows[key] = w
+	return w
+}
+
+// setDashboardCurrent use the latest group which has data as the current status
+func setDashboardCurrent(d *DashboardCluster, stats []PingSatistic) {
+	for _, s := range stats { // the latest is the first
+		if s.Count == 0 {
+			continue
+		}
+		d.HasData = true
+		d.LastUpdate = time.Unix(s.TimeStamp, 0).UTC().Format(time.RFC3339)
+		d.Loss = fmt.Sprintf("%3.1f%%", s.Loss*100)
+		d.Latency = fmt.Sprintf("mean %3.0f ms, p90 %d ms", s.TimeStatistic.Mean, s.TimeStatistic.P90)
+		switch {
+		case s.Loss*100 < DefaultAlertThredHold:
+			d.LossLevel = "ok"
+		case s.Loss*100 < 50:
+			d.LossLevel = "warn"
+		default:
+			d.LossLevel = "bad"
+		}
+		return
+	}
+}
+
+func svgLossChart(stats []PingSatistic, from int64, to int64) template.HTML {
+	return svgLineChart(stats, from, to, "loss %", "#cf222e", 100, func(s PingSatistic) float64 { return s.Loss * 100 })
+}
+
+func svgLatencyChart(stats []PingSatistic, from int64, to int64) template.HTML {
+	maxLatency := float64(1000)
+	for _, s := range stats {
+		if s.Count > 0 && s.TimeStatistic.Mean > maxLatency {
+			maxLatency = s.TimeStatistic.Mean
+		}
+	}
+	return svgLineChart(stats, from, to, "mean ms", "#0969da", maxLatency, func(s PingSatistic) float64 { return s.TimeStatistic.Mean })
+}
+
+// svgLineChart draw value of stats as a line. Groups without data break the line
+func svgLineChart(stats []PingSatistic, from int64, to int64, label string, color string, maxValue float64, value func(PingSatistic) float64) template.HTML {
+	const padLeft, padTop, padBottom = 48, 8, 16
+	plotW := float64(dashboardChartWidth - padLeft - 8)
+	plotH := float64(dashboardChartHeight - padTop - padBottom)
+	var path, dots strings.Builder
+	penDown := false
+	for i := len(stats) - 1; i >= 0; i-- { // from the oldest
+		s := stats[i]
+		if s.Count == 0 {
+			penDown = false
+			continue
+		}
+		v := value(s)
+		if v > maxValue {
+			v = maxValue
+		}
+		x := padLeft + plotW*float64(s.TimeStamp-from)/float64(to-from)
+		y := padTop + plotH*(1-v/maxValue)
+		fmt.Fprintf(&dots, `<circle cx="%.1f" cy="%.1f" r="1.2" fill="%s"/>`, x, y, color)
+		if penDown {
+			fmt.Fprintf(&path, "L%.1f %.1f ", x, y)
+		} else {
+			fmt.Fprintf(&path, "M%.1f %.1f ", x, y)
+			penDown = true
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		dashboardChartWidth, dashboardChartHeight, dashboardChartWidth, dashboardChartHeight)
+	fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ccc"/>`, padLeft, padTop, padLeft, dashboardChartHeight-padBottom)
+	fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ccc"/>`, padLeft, dashboardChartHeight-padBottom, dashboardChartWidth-8, dashboardChartHeight-padBottom)
+	fmt.Fprintf(&sb, `<text x="2" y="%d" font-size="10" fill="#777">%.0f</text>`, padTop+8, maxValue)
+	fmt.Fprintf(&sb, `<text x="2" y="%d" font-size="10" fill="#777">0</text>`, dashboardChartHeight-padBottom)
+	fmt.Fprintf(&sb, `<text x="2" y="%d" font-size="10" fill="#777">%s</text>`, dashboardChartHeight/2, template.HTMLEscapeString(label))
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="10" fill="#777">%s</text>`, padLeft, dashboardChartHeight-2, time.Unix(from, 0).UTC().Format("01-02 15:04"))
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="10" fill="#777" text-anchor="end">%s UTC</text>`, dashboardChartWidth-8, dashboardChartHeight-2, time.Unix(to, 0).UTC().Format("01-02 15:04"))
+	if path.Len() > 0 {
+		fmt.Fprintf(&sb, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5"/>`, strings.TrimSpace(path.String()), color)
+	}
+	sb.WriteString(dots.String())
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
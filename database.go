@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/lib/pq"
+	"gorm.io/gorm"
 )
 
 // ComputeUnitPriceType tell program fetch what kind of compute data to fetch
@@ -23,6 +25,7 @@ type PingResult struct {
 	Cluster             string
 	Hostname            string
 	PingType            string `gorm:"NOT NULL"`
+	TxType              string // PingTxType. empty is transfer
 	Submitted           int    `gorm:"NOT NULL"`
 	Confirmed           int    `gorm:"NOT NULL"`
 	Loss                float64
@@ -31,18 +34,109 @@ type PingResult struct {
 	Min                 int64
 	Stddev              int64
 	TakeTime            int64
+	P50                 int64
+	P90                 int64
+	P99                 int64
+	BlockhashTime       int64 // mean take time (ms) of each stage. see PingStageNames
+	SendTime            int64
+	ProcessedTime       int64
+	ConfirmedTime       int64
+	FinalizedTime       int64
+	SlotCount           int     // number of confirmed txs whose landed slot is known
+	SendSlotDelta       float64 // mean of landed slot - processed slot before sending
+	BlockhashSlotDelta  float64 // mean of landed slot - slot of the blockhash
 	RequestComputeUnits uint32
 	ComputeUnitPrice    uint64
+	FeeStrategy         string         // FeeStrategyType which decides ComputeUnitPrice
+	FeeInputs           string         // parameters and data used by FeeStrategy
 	Error               pq.StringArray `gorm:"type:text[];"NOT NULL"`
+	TakeTimes           []int64        `gorm:"-" json:"-"`             // take time of each tx. not stored
+	TxTimes             pq.Int64Array  `gorm:"type:bigint[]" json:"-"` // take time (ms) of each confirmed tx
+	TxStages            pq.Int64Array  `gorm:"type:bigint[]" json:"-"` // PingStages of each tx, NumPingStages values per tx
 	CreatedAt           time.Time      `gorm:"type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP" json:"created_at,omitempty"`
 	UpdatedAt           time.Time      `gorm:"type:timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP" json:"updated_at,omitempty"`
 }
 
+// migrateDatabase add new columns into the existing tables. Existing columns are not modified
+func migrateDatabase() error {
+	if !database.Migrator().HasTable(&AlertEvent{}) {
+		if err := database.Migrator().CreateTable(&AlertEvent{}); err != nil {
+			return err
+		}
+	}
+	if !database.Migrator().HasTable(&PingResult{}) {
+		return database.Migrator().CreateTable(&PingResult{})
+	}
+	return addColumnsIfNotExist(&PingResult{}, "P50", "P90", "P99", "TxType",
+		"BlockhashTime", "SendTime", "ProcessedTime", "ConfirmedTime", "FinalizedTime",
+		"SlotCount", "SendSlotDelta", "BlockhashSlotDelta", "FeeStrategy", "FeeInputs", "TxTimes", "TxStages")
+}
+
+// Stages return the mean take time of each stage
+func (r *PingResult) Stages() PingStages {
+	return PingStages{r.BlockhashTime, r.SendTime, r.ProcessedTime, r.ConfirmedTime, r.FinalizedTime}
+}
+
+func (r *PingResult) SetStages(s PingStages) {
+	r.BlockhashTime, r.SendTime, r.ProcessedTime, r.ConfirmedTime, r.FinalizedTime =
+		s[StageBlockhash], s[StageSend], s[StageProcessed], s[StageConfirmed], s[StageFinalized]
+}
+
+// ConfirmationTimes return the take time of each confirmed tx. Results stored before TxTimes have only TakeTime,
+// which is the sum of the batch, so it is the take time of a tx only if BatchCount is 1
+func (r *PingResult) ConfirmationTimes() []int64 {
+	if len(r.TxTimes) == 0 && r.Confirmed > 0 && len(r.Error) == 0 {
+		return []int64{r.TakeTime}
+	}
+	return r.TxTimes
+}
+
+// StagesOfTxs return the stages of each tx. Results stored before TxStages have only the mean of the batch
+func (r *PingResult) StagesOfTxs() []PingStages {
+	if len(r.TxStages) == 0 {
+		return []PingStages{r.Stages()}
+	}
+	ret := make([]PingStages, 0, len(r.TxStages)/NumPingStages)
+	for i := 0; i+NumPingStages <= len(r.TxStages); i += NumPingStages {
+		s := PingStages{}
+		copy(s[:], r.TxStages[i:i+NumPingStages])
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func addColumnsIfNotExist(model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if database.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := database.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func addRecord(data PingResult) error {
 	result := database.Create(&data)
 	return result.Error
 }
 
+func addAlertEvent(e AlertEvent) error {
+	return database.Create(&e).Error
+}
+
+// getAlertEvents return alert events of the cluster in (from, to]. The latest is the first. limit <= 0 means no limit
+func getAlertEvents(c Cluster, from int64, to int64, limit int) []AlertEvent {
+	ret := []AlertEvent{}
+	query := database.Order("time_stamp desc").Where("cluster=? AND time_stamp > ? AND time_stamp <= ?", c, from, to)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	query.Find(&ret)
+	return ret
+}
+
 func getLastN(c Cluster, pType PingType, n int, priceType ComputeUnitPriceType, threshold uint64) []PingResult {
 	ret := []PingResult{}
 	switch priceType {
@@ -78,6 +172,85 @@ func getAfter(c Cluster, pType PingType, t int64, priceType ComputeUnitPriceType
 	return ret
 }
 
+// PingResultFilter select PingResult rows. Empty PingType/Hostname match all
+type PingResultFilter struct {
+	Cluster     Cluster
+	PingType    PingType
+	Hostname    string
+	From        int64 // exclusive
+	To          int64 // inclusive
+	PriceType   ComputeUnitPriceType
+	Threshold   uint64
+	MinPrice    *uint64 // compute_unit_price >= MinPrice
+	MaxPrice    *uint64 // compute_unit_price <= MaxPrice
+	PriceTier   *uint64 // compute_unit_price = PriceTier
+	FeeStrategy string  // fee_strategy = FeeStrategy. empty matches all
+}
+
+// HasFeeFilter return true if any of MinPrice/MaxPrice/PriceTier/FeeStrategy is set
+func (f PingResultFilter) HasFeeFilter() bool {
+	return f.MinPrice != nil || f.MaxPrice != nil || f.PriceTier != nil || len(f.FeeStrategy) > 0
+}
+
+func (f PingResultFilter) query(db *gorm.DB) *gorm.DB {
+	q := db.Model(&PingResult{}).Where("cluster=? AND time_stamp > ? AND time_stamp <= ?", f.Cluster, f.From, f.To)
+	if len(f.PingType) > 0 {
+		q = q.Where("ping_type=?", string(f.PingType))
+	}
+	if len(f.Hostname) > 0 {
+		q = q.Where("hostname=?", f.Hostname)
+	}
+	switch f.PriceType {
+	case NoComputeUnitPrice:
+		q = q.Where("compute_unit_price = ?", 0)
+	case HasComputeUnitPrice:
+		q = q.Where("compute_unit_price > ?", 0)
+	case ComputeUnitPriceThreshold:
+		q = q.Where("compute_unit_price > ?", f.Threshold)
+	}
+	if f.MinPrice != nil {
+		q = q.Where("compute_unit_price >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		q = q.Where("compute_unit_price <= ?", *f.MaxPrice)
+	}
+	if f.PriceTier != nil {
+		q = q.Where("compute_unit_price = ?", *f.PriceTier)
+	}
+	if len(f.FeeStrategy) > 0 {
+		q = q.Where("fee_strategy = ?", f.FeeStrategy)
+	}
+	return q
+}
+
+// getByFilter return all rows matched the filter
+func getByFilter(f PingResultFilter) []PingResult {
+	ret := []PingResult{}
+	f.query(database).Find(&ret)
+	return ret
+}
+
+// forEachPingResult iterate rows matched the filter in time order without loading all of them into memory
+func forEachPingResult(ctx context.Context, f PingResultFilter, fn func(*PingResult) error) error {
+	db := database.WithContext(ctx)
+	rows, err := f.query(db).Order("time_stamp").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r := PingResult{}
+		if err := db.ScanRows(rows, &r); err != nil {
+			return err
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func deleteTimeBefore(t int64) {
 	database.Where("time_stamp < ?", t).Delete(&[]PingResult{})
+	database.Where("time_stamp < ?", t).Delete(&[]AlertEvent{})
 }
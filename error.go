@@ -26,6 +26,30 @@ var (
 	ErrWaitForConfirmedTimeout = errors.New("Wait for a confirmed block timeout")
 	ErrGetKeyPair              = errors.New("No valid KeyPair")
 	ErrKeyPairFile             = errors.New("Read KeyPair File Error")
+	ErrInvalidTimeFormat       = errors.New("invalid time format, use unix seconds or RFC3339")
+	ErrInvalidTimeRange        = errors.New("invalid time range, from must be earlier than to")
+	ErrInvalidStep             = errors.New("invalid step, supported steps are 1m, 5m, 15m, 1h")
+	ErrTooManyGroups           = errors.New("too many data points, use a larger step or a shorter range")
+	ErrRangeTooLong            = errors.New("time range is too long, it must be at most 7 days")
+	ErrInvalidPriceType        = errors.New("invalid price, supported prices are all, zero, hasprice, threshold")
+	ErrInvalidThreshold        = errors.New("invalid threshold, threshold must be a unsigned integer")
+	ErrInvalidPriceFilter      = errors.New("invalid min_price/max_price/price_tier, they must be unsigned integers and min_price <= max_price")
+	ErrInvalidExportFormat     = errors.New("invalid format, supported formats are csv, ndjson")
+	ErrInvalidLossThreshold    = errors.New("invalid loss_threshold, it must be a percentage in (0, 100]")
+	ErrInvalidCompareClusters  = errors.New("invalid clusters, it must be 2 to 5 cluster names in url separated by comma")
+	ErrInvalidBaseline         = errors.New("invalid baseline, it must be one of clusters")
+	ErrInvalidOpenAPISpec      = errors.New("openapi document has no cluster parameter")
+	ErrInvalidEndpointIndex    = errors.New("invalid endpoint index")
+	ErrNoACMEDomain            = errors.New("ACME is enabled but no domain is configured")
+	ErrInvalidLatencySLO       = errors.New("invalid latency_slo, it must be a positive integer of ms")
+	ErrInvalidTxType           = errors.New("invalid TxType, supported types are transfer, memo, spl-token, custom")
+	ErrInvalidTxConfig         = errors.New("invalid ping tx config")
+	ErrInvalidPublicKey        = errors.New("invalid public key")
+	ErrInvalidConfirmationMode = errors.New("invalid ConfirmationMode, supported modes are polling, websocket")
+	ErrWebsocketUnavailable    = errors.New("websocket is unavailable")
+	ErrInvalidFeeStrategy      = errors.New("invalid FeeStrategy")
+	ErrNoInfluxFee             = errors.New("InfluxQuery returns no numeric value")
+	ErrInvalidFeeStrategyParam = errors.New("invalid fee_strategy, supported strategies are max, fixed, percentile, median-multiplier, influx, ladder")
 )
 
 // Setup Statistic / Alert / Report Error Exception List
@@ -94,6 +118,19 @@ func (p PingResultError) Short() string {
 	return string(p)
 }
 
+// OtherErrorCategory is the category of errors which are not in ResponseErrIdentifierList
+const OtherErrorCategory = "other"
+
+// Category return the short name of a known error or OtherErrorCategory
+func (p PingResultError) Category() string {
+	for _, idf := range ResponseErrIdentifierList {
+		if idf.IsIdentical(p) {
+			return idf.Short
+		}
+	}
+	return OtherErrorCategory
+}
+
 func (p PingResultError) Subsitute(old string, new string) string {
 	return strings.ReplaceAll(string(p), old, new)
 }
@@ -114,6 +151,10 @@ func ResponseErrIdentifierInit() []ErrRespIdentifier {
 	ResponseErrIdentifierList = append(ResponseErrIdentifierList, GatewayTimeout504)
 	ResponseErrIdentifierList = append(ResponseErrIdentifierList, NoSuchHost)
 	ResponseErrIdentifierList = append(ResponseErrIdentifierList, TxHasAlreadyProcess)
+	ResponseErrIdentifierList = append(ResponseErrIdentifierList, WaitConfirmedTimeout)
+	ResponseErrIdentifierList = append(ResponseErrIdentifierList, ProcessedStateTimeout)
+	ResponseErrIdentifierList = append(ResponseErrIdentifierList, BlockhashNotValid)
+	ResponseErrIdentifierList = append(ResponseErrIdentifierList, ConfirmationExceeds)
 	return ResponseErrIdentifierList
 }
 
@@ -44,6 +44,14 @@ var (
 	TxHasAlreadyProcessText = `rpc response error: {"code":-32002,"message":"Transaction simulation failed: This transaction has already been processed","data":{"accounts":null,"err":"AlreadyProcessed","logs":[],"unitsConsumed":0}}`
 )
 
+// errors of waiting for confirmations
+var (
+	WaitConfirmedTimeoutText  = ErrWaitForConfirmedTimeout.Error()
+	ProcessedStateTimeoutText = ErrInProcessedStateTimeout.Error()
+	BlockhashNotValidText     = `blockhash is not valid, txHash: 5bN7Z..., blockhash: 9xQeW..., err: <nil>`
+	ConfirmationExceedsText   = `the confirmation process exceeds 3 mins, txHash: 5bN7Z..., blockhash: 9xQeW...`
+)
+
 var (
 	BlockhashNotFound = ErrRespIdentifier{
 		Text:  PingResultError(BlockhashNotFoundText),
@@ -89,6 +97,22 @@ var (
 		Text:  PingResultError(TxHasAlreadyProcessText),
 		Key:   []string{"transaction has already been processed"},
 		Short: "tx-has-been-processed"}
+	WaitConfirmedTimeout = ErrRespIdentifier{
+		Text:  PingResultError(WaitConfirmedTimeoutText),
+		Key:   []string{WaitConfirmedTimeoutText},
+		Short: "wait-confirmed-timeout"}
+	ProcessedStateTimeout = ErrRespIdentifier{
+		Text:  PingResultError(ProcessedStateTimeoutText),
+		Key:   []string{ProcessedStateTimeoutText},
+		Short: "processed-state-timeout"}
+	BlockhashNotValid = ErrRespIdentifier{
+		Text:  PingResultError(BlockhashNotValidText),
+		Key:   []string{"blockhash is not valid"},
+		Short: "blockhash-expired"}
+	ConfirmationExceeds = ErrRespIdentifier{
+		Text:  PingResultError(ConfirmationExceedsText),
+		Key:   []string{"the confirmation process exceeds"},
+		Short: "confirmation-exceeds-3mins"}
 )
 
 func (e ErrRespIdentifier) IsIdentical(p PingResultError) bool {
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	// exportFlushRows flush the response every exportFlushRows rows
+	exportFlushRows = 500
+)
+
+// ExportRowJSON is a raw PingResult row in export
+type ExportRowJSON struct {
+	TimeStamp           int64    `json:"ts"`
+	Cluster             string   `json:"cluster"`
+	Hostname            string   `json:"hostname"`
+	PingType            string   `json:"ping_type"`
+	TxType              string   `json:"tx_type"`
+	Submitted           int      `json:"submitted"`
+	Confirmed           int      `json:"confirmed"`
+	Loss                float64  `json:"loss"`
+	Max                 int64    `json:"max_ms"`
+	Mean                int64    `json:"mean_ms"`
+	Min                 int64    `json:"min_ms"`
+	Stddev              int64    `json:"stddev_ms"`
+	P50                 int64    `json:"p50_ms"`
+	P90                 int64    `json:"p90_ms"`
+	P99                 int64    `json:"p99_ms"`
+	TakeTime            int64    `json:"take_time_ms"`
+	BlockhashTime       int64    `json:"blockhash_ms"`
+	SendTime            int64    `json:"send_ms"`
+	ProcessedTime       int64    `json:"processed_ms"`
+	ConfirmedTime       int64    `json:"confirmed_ms"`
+	FinalizedTime       int64    `json:"finalized_ms"`
+	SlotCount           int      `json:"slot_count"`
+	SendSlotDelta       float64  `json:"send_slot_delta"`
+	BlockhashSlotDelta  float64  `json:"blockhash_slot_delta"`
+	RequestComputeUnits uint32   `json:"request_compute_units"`
+	ComputeUnitPrice    uint64   `json:"compute_unit_price"`
+	FeeStrategy         string   `json:"fee_strategy"`
+	FeeInputs           string   `json:"fee_inputs"`
+	Error               []string `json:"error"`
+}
+
+var exportCSVHeader = []string{"ts", "cluster", "hostname", "ping_type", "tx_type", "submitted", "confirmed", "loss",
+	"max_ms", "mean_ms", "min_ms", "stddev_ms", "p50_ms", "p90_ms", "p99_ms", "take_time_ms",
+	"blockhash_ms", "send_ms", "processed_ms", "confirmed_ms", "finalized_ms",
+	"slot_count", "send_slot_delta", "blockhash_slot_delta",
+	"request_compute_units", "compute_unit_price", "fee_strategy", "fee_inputs", "error"}
+
+func toExportRow(r *PingResult) ExportRowJSON {
+	errs := []string(r.Error)
+	if errs == nil {
+		errs = []string{}
+	}
+	return ExportRowJSON{
+		TimeStamp:           r.TimeStamp,
+		Cluster:             r.Cluster,
+		Hostname:            r.Hostname,
+		PingType:            r.PingType,
+		TxType:              r.TxType,
+		Submitted:           r.Submitted,
+		Confirmed:           r.Confirmed,
+		Loss:                r.Loss,
+		Max:                 r.Max,
+		Mean:                r.Mean,
+		Min:                 r.Min,
+		Stddev:              r.Stddev,
+		P50:                 r.P50,
+		P90:                 r.P90,
+		P99:                 r.P99,
+		TakeTime:            r.TakeTime,
+		BlockhashTime:       r.BlockhashTime,
+		SendTime:            r.SendTime,
+		ProcessedTime:       r.ProcessedTime,
+		ConfirmedTime:       r.ConfirmedTime,
+		FinalizedTime:       r.FinalizedTime,
+		SlotCount:           r.SlotCount,
+		SendSlotDelta:       r.SendSlotDelta,
+		BlockhashSlotDelta:  r.BlockhashSlotDelta,
+		RequestComputeUnits: r.RequestComputeUnits,
+		ComputeUnitPrice:    r.ComputeUnitPrice,
+		FeeStrategy:         r.FeeStrategy,
+		FeeInputs:           r.FeeInputs,
+		Error:               errs,
+	}
+}
+
+func (r ExportRowJSON) csvRecord() []string {
+	return []string{
+		strconv.FormatInt(r.TimeStamp, 10),
+		r.Cluster,
+		r.Hostname,
+		r.PingType,
+		r.TxType,
+		strconv.Itoa(r.Submitted),
+		strconv.Itoa(r.Confirmed),
+		strconv.FormatFloat(r.Loss, 'f', -1, 64),
+		strconv.FormatInt(r.Max, 10),
+		strconv.FormatInt(r.Mean, 10),
+		strconv.FormatInt(r.Min, 10),
+		strconv.FormatInt(r.Stddev, 10),
+		strconv.FormatInt(r.P50, 10),
+		strconv.FormatInt(r.P90, 10),
+		strconv.FormatInt(r.P99, 10),
+		strconv.FormatInt(r.TakeTime, 10),
+		strconv.FormatInt(r.BlockhashTime, 10),
+		strconv.FormatInt(r.SendTime, 10),
+		strconv.FormatInt(r.ProcessedTime, 10),
+		strconv.FormatInt(r.ConfirmedTime, 10),
+		strconv.FormatInt(r.FinalizedTime, 10),
+		strconv.Itoa(r.SlotCount),
+		strconv.FormatFloat(r.SendSlotDelta, 'f', -1, 64),
+		strconv.FormatFloat(r.BlockhashSlotDelta, 'f', -1, 64),
+		strconv.FormatUint(uint64(r.RequestComputeUnits), 10),
+		strconv.FormatUint(r.ComputeUnitPrice, 10),
+		r.FeeStrategy,
+		r.FeeInputs,
+		strings.Join(r.Error, ";"),
+	}
+}
+
+// parseExportFilter parse the query of an export request. default time range is last 6 hours
+func parseExportFilter(c *gin.Context, cluster Cluster) (PingResultFilter, error) {
+	filter := PingResultFilter{
+		Cluster:  cluster,
+		PingType: PingType(c.Query("ping_type")),
+		Hostname: c.Query("hostname"),
+	}
+	var err error
+	filter.PriceType, filter.Threshold, err = parsePriceParams(c, AllData)
+	if err != nil {
+		return filter, err
+	}
+	if err = parseFeeRangeParams(c, &filter); err != nil {
+		return filter, err
+	}
+	filter.From, filter.To, err = parseTimeRangeParams(c)
+	return filter, err
+}
+
+// exportPingResult stream raw PingResult rows of the cluster as csv or ndjson
+func exportPingResult(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	if format != ExportFormatCSV && format != ExportFormatNDJSON {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidExportFormat.Error()})
+		return
+	}
+	filter, err := parseExportFilter(c, cluster)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filename := string(cluster) + "-" + strconv.FormatInt(filter.From, 10) + "-" + strconv.FormatInt(filter.To, 10) + "." + format
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	count := 0
+	if format == ExportFormatCSV {
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		w.Write(exportCSVHeader)
+		err = forEachPingResult(c.Request.Context(), filter, func(r *PingResult) error {
+			if err := w.Write(toExportRow(r).csvRecord()); err != nil {
+				return err
+			}
+			count++
+			if count%exportFlushRows == 0 {
+				w.Flush()
+				c.Writer.Flush()
+			}
+			return nil
+		})
+		w.Flush()
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		err = forEachPingResult(c.Request.Context(), filter, func(r *PingResult) error {
+			if err := encoder.Encode(toExportRow(r)); err != nil {
+				return err
+			}
+			count++
+			if count%exportFlushRows == 0 {
+				c.Writer.Flush()
+			}
+			return nil
+		})
+	}
+	if err != nil { // header has been sent. only log the error
+		log.Println("export ", cluster, " error:", err, " rows:", count)
+	}
+}
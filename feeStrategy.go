@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+)
+
+// FeeStrategyType is how the compute unit price of a ping tx is decided
+type FeeStrategyType string
+
+const (
+	MaxFee              FeeStrategyType = "max"               // max of recent prioritization fees
+	FixedFee            FeeStrategyType = "fixed"             // PingConfig.ComputeUnitPrice
+	PercentileFee       FeeStrategyType = "percentile"        // Percentile of recent prioritization fees
+	MedianMultiplierFee FeeStrategyType = "median-multiplier" // median of recent prioritization fees x Multiplier
+	InfluxFee           FeeStrategyType = "influx"            // the first value of InfluxQuery. max if the query fails
+	LadderFee           FeeStrategyType = "ladder"            // a tier of PingConfig.FeeLadder
+)
+
+const (
+	// recentFeeCount is the number of the latest slots used from getRecentPrioritizationFees
+	recentFeeCount = 100
+	// DefaultFeeCap is the default upper bound (micro lamports) of the compute unit price
+	DefaultFeeCap = uint64(100_000_000)
+	// minFee is at least 1 to trigger the system send the fee tx. can be updated if the logic is fixed.
+	minFee             = uint64(1)
+	influxQueryTimeout = 5 * time.Second
+)
+
+// FeePrice is the compute unit price of a ping and how it is decided
+type FeePrice struct {
+	Price    uint64
+	Strategy FeeStrategyType
+	Inputs   string // parameters and data used by the strategy, e.g. percentile=75,samples=100,value=1200
+}
+
+// FeeStrategy decide the compute unit price of ping txs
+type FeeStrategy struct {
+	conf        FeeStrategyConfig
+	fixedPrice  uint64
+	tier        int // index in FeeLadder of LadderFee
+	influxQuery api.QueryAPI
+}
+
+// NewFeeStrategy create the FeeStrategy of FeeStrategy.Type in config. Default is MaxFee
+func NewFeeStrategy(conf PingConfig, influxConf InfluxdbConfig) (*FeeStrategy, error) {
+	s := &FeeStrategy{conf: conf.FeeStrategy, fixedPrice: conf.ComputeUnitPrice}
+	if len(s.conf.Type) == 0 {
+		s.conf.Type = string(MaxFee)
+	}
+	if s.conf.Cap == 0 {
+		s.conf.Cap = DefaultFeeCap
+	}
+	switch FeeStrategyType(s.conf.Type) {
+	case MaxFee, FixedFee:
+	case PercentileFee:
+		if s.conf.Percentile <= 0 || s.conf.Percentile > 100 {
+			return nil, fmt.Errorf("%w: Percentile must be in (0, 100]", ErrInvalidFeeStrategy)
+		}
+	case MedianMultiplierFee:
+		if s.conf.Multiplier <= 0 {
+			return nil, fmt.Errorf("%w: Multiplier must be positive", ErrInvalidFeeStrategy)
+		}
+	case InfluxFee:
+		if len(s.conf.InfluxQuery) == 0 || len(influxConf.InfluxdbURL) == 0 {
+			return nil, fmt.Errorf("%w: InfluxQuery and InfluxdbConfig are required", ErrInvalidFeeStrategy)
+		}
+		s.influxQuery = influxdb2.NewClient(influxConf.InfluxdbURL, influxConf.AccessToken).QueryAPI(influxConf.Orgnization)
+	default:
+		return nil, fmt.Errorf("%w: unknown type %s", ErrInvalidFeeStrategy, s.conf.Type)
+	}
+	return s, nil
+}
+
+// NewFeeLadder create a LadderFee FeeStrategy for each tier of FeeLadder. Tiers must be unique and under Cap.
+// It returns nil if FeeLadder is empty
+func NewFeeLadder(conf PingConfig) ([]*FeeStrategy, error) {
+	if len(conf.FeeLadder) == 0 {
+		return nil, nil
+	}
+	if conf.ComputeFeeDualMode {
+		return nil, fmt.Errorf("%w: FeeLadder replaces ComputeFeeDualMode, use a 0 tier instead", ErrInvalidFeeStrategy)
+	}
+	feeCap := conf.FeeStrategy.Cap
+	if feeCap == 0 {
+		feeCap = DefaultFeeCap
+	}
+	ret := make([]*FeeStrategy, 0, len(conf.FeeLadder))
+	seen := map[uint64]bool{}
+	for i, price := range conf.FeeLadder {
+		if seen[price] || price > feeCap {
+			return nil, fmt.Errorf("%w: FeeLadder[%d] %d is duplicated or over Cap", ErrInvalidFeeStrategy, i, price)
+		}
+		seen[price] = true
+		ret = append(ret, &FeeStrategy{conf: FeeStrategyConfig{Type: string(LadderFee), Cap: feeCap}, fixedPrice: price, tier: i})
+	}
+	return ret, nil
+}
+
+// Price return the compute unit price in [1, Cap]
+func (s *FeeStrategy) Price(c *client.Client, acct types.Account) FeePrice {
+	var ret FeePrice
+	switch FeeStrategyType(s.conf.Type) {
+	case FixedFee:
+		ret = FeePrice{Price: s.fixedPrice, Strategy: FixedFee, Inputs: "value=" + strconv.FormatUint(s.fixedPrice, 10)}
+	case LadderFee:
+		ret = FeePrice{Price: s.fixedPrice, Strategy: LadderFee, Inputs: "tier=" + strconv.Itoa(s.tier) + ",value=" + strconv.FormatUint(s.fixedPrice, 10)}
+	case InfluxFee:
+		price, err := s.queryInfluxFee()
+		if err != nil {
+			ret = recentFeePrice(MaxFee, getRecentFees(c, acct), 100)
+			ret.Inputs += ",fallback=" + string(InfluxFee)
+		} else {
+			ret = FeePrice{Price: price, Strategy: InfluxFee, Inputs: "value=" + strconv.FormatUint(price, 10)}
+		}
+	case PercentileFee:
+		ret = recentFeePrice(PercentileFee, getRecentFees(c, acct), s.conf.Percentile)
+		ret.Inputs = "percentile=" + strconv.FormatFloat(s.conf.Percentile, 'f', -1, 64) + "," + ret.Inputs
+	case MedianMultiplierFee:
+		ret = recentFeePrice(MedianMultiplierFee, getRecentFees(c, acct), 50)
+		ret.Inputs = "multiplier=" + strconv.FormatFloat(s.conf.Multiplier, 'f', -1, 64) + "," + ret.Inputs
+		ret.Price = uint64(math.Round(float64(ret.Price) * s.conf.Multiplier))
+	default:
+		ret = recentFeePrice(MaxFee, getRecentFees(c, acct), 100)
+	}
+	if ret.Price < minFee {
+		ret.Price = minFee
+	}
+	if ret.Price > s.conf.Cap {
+		ret.Price = s.conf.Cap
+		ret.Inputs += ",capped=" + strconv.FormatUint(s.conf.Cap, 10)
+	}
+	return ret
+}
+
+// getRecentFees return the prioritization fees of the latest recentFeeCount slots. nil if it fails
+func getRecentFees(c *client.Client, account types.Account) []uint64 {
+	fees, err := c.GetRecentPrioritizationFees(context.Background(), []common.PublicKey{account.PublicKey})
+	if err != nil {
+		return nil
+	}
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].Slot > fees[j].Slot
+	})
+	ret := make([]uint64, 0, recentFeeCount)
+	for i := 0; i < len(fees) && i < recentFeeCount; i++ {
+		ret = append(ret, fees[i].PrioritizationFee)
+	}
+	return ret
+}
+
+// recentFeePrice return the nearest-rank percentile of fees. 100 is the max
+func recentFeePrice(strategy FeeStrategyType, fees []uint64, percentile float64) FeePrice {
+	ret := FeePrice{Strategy: strategy, Inputs: "samples=" + strconv.Itoa(len(fees))}
+	if len(fees) == 0 {
+		return ret
+	}
+	sorted := append([]uint64{}, fees...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	ret.Price = sorted[rank-1]
+	ret.Inputs += ",value=" + strconv.FormatUint(ret.Price, 10)
+	return ret
+}
+
+// feeStrategyMemo summarize submitted txs and loss of each strategy for reports
+func feeStrategyMemo(data []PingResult) string {
+	submitted := map[string]int{}
+	confirmed := map[string]int{}
+	strategies := []string{}
+	for _, r := range data {
+		if _, ok := submitted[r.FeeStrategy]; !ok {
+			strategies = append(strategies, r.FeeStrategy)
+		}
+		submitted[r.FeeStrategy] += r.Submitted
+		confirmed[r.FeeStrategy] += r.Confirmed
+	}
+	sort.Strings(strategies)
+	outputs := make([]string, 0, len(strategies))
+	for _, strategy := range strategies {
+		name := strategy
+		if len(name) == 0 {
+			name = "unknown"
+		}
+		loss := float64(0)
+		if submitted[strategy] > 0 {
+			loss = float64(submitted[strategy]-confirmed[strategy]) / float64(submitted[strategy]) * 100
+		}
+		outputs = append(outputs, fmt.Sprintf("%s %dtx loss %3.1f%%", name, submitted[strategy], loss))
+	}
+	return strings.Join(outputs, ", ")
+}
+
+// queryInfluxFee return the value of the first record of InfluxQuery
+func (s *FeeStrategy) queryInfluxFee() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), influxQueryTimeout)
+	defer cancel()
+	result, err := s.influxQuery.Query(ctx, s.conf.InfluxQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+	if !result.Next() {
+		if result.Err() != nil {
+			return 0, result.Err()
+		}
+		return 0, ErrNoInfluxFee
+	}
+	switch v := result.Record().Value().(type) {
+	case float64:
+		return uint64(math.Max(v, 0)), nil
+	case int64:
+		if v < 0 {
+			return 0, nil
+		}
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	}
+	return 0, ErrNoInfluxFee
+}
@@ -0,0 +1,87 @@
+package main
+
+// LatencyHistogram is a histogram of take-time in ms. Bounds are the upper bound of each bucket.
+// The last bucket catch all values which are larger than the last bound.
+type LatencyHistogram struct {
+	Bounds []int64
+	Counts []int64
+	Total  int64
+	Min    int64
+	Max    int64
+}
+
+// DefaultLatencyBounds 50ms buckets up to 2s, 250ms up to 10s, 1s up to 60s and 5s up to 180s
+var DefaultLatencyBounds = func() []int64 {
+	bounds := []int64{}
+	for b := int64(50); b <= 2000; b += 50 {
+		bounds = append(bounds, b)
+	}
+	for b := int64(2250); b <= 10000; b += 250 {
+		bounds = append(bounds, b)
+	}
+	for b := int64(11000); b <= 60000; b += 1000 {
+		bounds = append(bounds, b)
+	}
+	for b := int64(65000); b <= 180000; b += 5000 {
+		bounds = append(bounds, b)
+	}
+	return bounds
+}()
+
+// NewLatencyHistogram create a histogram with DefaultLatencyBounds
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Bounds: DefaultLatencyBounds,
+		Counts: make([]int64, len(DefaultLatencyBounds)+1),
+	}
+}
+
+// Add put a take-time (ms) into the histogram
+func (h *LatencyHistogram) Add(ts int64) {
+	if h.Total == 0 || ts < h.Min {
+		h.Min = ts
+	}
+	if h.Total == 0 || ts > h.Max {
+		h.Max = ts
+	}
+	h.Total++
+	for i, b := range h.Bounds {
+		if ts <= b {
+			h.Counts[i]++
+			return
+		}
+	}
+	h.Counts[len(h.Bounds)]++
+}
+
+// Percentile return the p (0~100) percentile. Value is interpolated linearly inside a bucket
+// and clamped to the observed min/max. Return 0 if the histogram is empty.
+func (h *LatencyHistogram) Percentile(p float64) int64 {
+	if h.Total == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(h.Total)
+	cumulative := int64(0)
+	for i, count := range h.Counts {
+		if count == 0 {
+			continue
+		}
+		if float64(cumulative+count) >= rank {
+			lower := h.Min
+			if i > 0 && h.Bounds[i-1] > lower {
+				lower = h.Bounds[i-1]
+			}
+			upper := h.Max
+			if i < len(h.Bounds) && h.Bounds[i] < upper {
+				upper = h.Bounds[i]
+			}
+			fraction := (rank - float64(cumulative)) / float64(count)
+			if fraction < 0 {
+				fraction = 0
+			}
+			return lower + int64(fraction*float64(upper-lower))
+		}
+		cumulative += count
+	}
+	return h.Max
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// HistoryCacheWindow is the time range (seconds) of results kept in HistoryCache
+	HistoryCacheWindow = int64(6 * 60 * 60)
+	// historyCacheKeep keep a few more minutes than HistoryCacheWindow for the last partial minute
+	historyCacheKeep = HistoryCacheWindow + 5*60
+	// historyCacheResync is the time range (seconds) before the last refresh which is reloaded by each refresh,
+	// so that results inserted a little later than their timestamp are not missed
+	historyCacheResync = int64(2 * 60)
+	// HistoryCacheRefreshInterval is the interval of reloading recent results from database
+	HistoryCacheRefreshInterval = 10 * time.Second
+)
+
+// historyBucket is the results of a minute and their statistic of each price type
+type historyBucket struct {
+	results []PingResult
+	stats   map[ComputeUnitPriceType]PingSatistic // computed when requested. reset when a result is added
+}
+
+// HistoryCache keep DataPoint1Min results of last hours grouped by minute so that
+// the history endpoints do not have to query and group the database on every request.
+// It is refreshed from database by historyCacheWorker, so it has the results of every host as the database path does.
+type HistoryCache struct {
+	mutex   sync.Mutex
+	enabled map[Cluster]bool
+	buckets map[Cluster]map[int64]*historyBucket       // cluster -> end of minute -> bucket
+	latest  map[Cluster]map[ComputeUnitPriceType]int64 // timestamp of the latest result of each price type
+	evicted map[Cluster]int64                          // the latest end of minute which has been evicted
+	synced  map[Cluster]int64                          // results at or before synced have been loaded
+}
+
+var historyCache = NewHistoryCache()
+
+func NewHistoryCache() *HistoryCache {
+	return &HistoryCache{
+		enabled: map[Cluster]bool{},
+		buckets: map[Cluster]map[int64]*historyBucket{},
+		latest:  map[Cluster]map[ComputeUnitPriceType]int64{},
+		evicted: map[Cluster]int64{},
+		synced:  map[Cluster]int64{},
+	}
+}
+
+// historyCacheWorker keep the cache of the cluster refreshed. The cache is enabled after the first refresh
+func historyCacheWorker(ctx context.Context, c Cluster) {
+	log.Println(">> History Cache Worker for ", c, " start!")
+	defer log.Println(">> History Cache Worker for ", c, " end!")
+	for ctx.Err() == nil {
+		if err := historyCache.Refresh(ctx, c); err != nil && ctx.Err() == nil {
+			log.Println("history cache of ", c, " refresh error:", err)
+		}
+		sleepContext(ctx, HistoryCacheRefreshInterval)
+	}
+}
+
+// Refresh load results of the cluster since the last refresh from database.
+// The first refresh loads the last HistoryCacheWindow and enables the cache.
+func (h *HistoryCache) Refresh(ctx context.Context, c Cluster) error {
+	now := time.Now().UTC().Unix()
+	h.mutex.Lock()
+	from := now - historyCacheKeep
+	if synced := h.synced[c]; synced > 0 && synced-historyCacheResync > from {
+		from = synced - historyCacheResync
+	}
+	h.mutex.Unlock()
+	from = from / 60 * 60 // reload whole minutes
+	records := []PingResult{}
+	err := forEachPingResult(ctx, PingResultFilter{Cluster: c, PingType: DataPoint1Min, PriceType: AllData, From: from, To: now}, func(r *PingResult) error {
+		records = append(records, *r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.Load(c, from, now, records)
+	return nil
+}
+
+// Load replace the results of the cluster in (from, to] with records and enable the cache of the cluster.
+// from must be the end of a minute.
+func (h *HistoryCache) Load(c Cluster, from int64, to int64, records []PingResult) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for minuteEnd := range h.buckets[c] {
+		if minuteEnd > from {
+			delete(h.buckets[c], minuteEnd)
+		}
+	}
+	for _, r := range records {
+		if r.TimeStamp > from && r.TimeStamp <= to {
+			h.add(c, r)
+		}
+	}
+	h.synced[c] = to
+	h.enabled[c] = true
+}
+
+// add put a DataPoint1Min result of the cluster into its minute. must be called with mutex locked
+func (h *HistoryCache) add(c Cluster, r PingResult) {
+	if PingType(r.PingType) != DataPoint1Min {
+		return
+	}
+	minuteEnd := (r.TimeStamp + 59) / 60 * 60
+	if minuteEnd <= h.evicted[c] {
+		return
+	}
+	if h.buckets[c] == nil {
+		h.buckets[c] = map[int64]*historyBucket{}
+		h.latest[c] = map[ComputeUnitPriceType]int64{}
+	}
+	b, ok := h.buckets[c][minuteEnd]
+	if !ok {
+		b = &historyBucket{}
+		h.buckets[c][minuteEnd] = b
+		h.evict(c, minuteEnd-historyCacheKeep)
+	}
+	r.TakeTimes = nil
+	b.results = append(b.results, r)
+	b.stats = nil
+	for _, priceType := range []ComputeUnitPriceType{AllData, NoComputeUnitPrice, HasComputeUnitPrice} {
+		if matchPriceType(r, priceType) && r.TimeStamp > h.latest[c][priceType] {
+			h.latest[c][priceType] = r.TimeStamp
+		}
+	}
+}
+
+// evict remove buckets ended at or before t. must be called with mutex locked
+func (h *HistoryCache) evict(c Cluster, t int64) {
+	if t <= h.evicted[c] {
+		return
+	}
+	for minuteEnd := range h.buckets[c] {
+		if minuteEnd <= t {
+			delete(h.buckets[c], minuteEnd)
+		}
+	}
+	h.evicted[c] = t
+}
+
+// Last6hours return the statistic of each minute of last 6 hours. The latest is the first.
+// It returns false if the cluster or the price type is not cached, and an empty list if there is no data.
+func (h *HistoryCache) Last6hours(c Cluster, priceType ComputeUnitPriceType) ([]PingSatistic, bool) {
+	if priceType != AllData && priceType != NoComputeUnitPrice && priceType != HasComputeUnitPrice {
+		return nil, false
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.enabled[c] {
+		return nil, false
+	}
+	end := last6hoursEnd(h.latest[c][priceType], time.Now().UTC().Unix())
+	cConf := GetClusterConfig(c)
+	ret := make([]PingSatistic, 0, HistoryCacheWindow/60)
+	hasData := false
+	for minuteEnd := end; minuteEnd > end-HistoryCacheWindow; minuteEnd -= 60 {
+		stat := h.statistic(c, cConf, minuteEnd, priceType)
+		if stat.Count > 0 {
+			hasData = true
+		}
+		ret = append(ret, stat)
+	}
+	if !hasData {
+		return []PingSatistic{}, true
+	}
+	return ret, true
+}
+
+// last6hoursEnd return the end of the latest minute group of last6hours. Groups are aligned to minutes,
+// so the cache and the database return the same timestamps. latest is the timestamp of the latest record
+func last6hoursEnd(latest int64, now int64) int64 {
+	if now-latest >= 60 { // no record in past one min, use now
+		latest = now
+	}
+	return (latest + 59) / 60 * 60
+}
+
+// statistic return the statistic of a minute. must be called with mutex locked
+func (h *HistoryCache) statistic(c Cluster, cConf ClusterConfig, minuteEnd int64, priceType ComputeUnitPriceType) PingSatistic {
+	b, ok := h.buckets[c][minuteEnd]
+	if ok {
+		if stat, ok := b.stats[priceType]; ok {
+			return stat
+		}
+	}
+	group := PingGroup{TimeStamp: minuteEnd, Window: 60}
+	if ok {
+		for _, r := range b.results {
+			if matchPriceType(r, priceType) {
+				group.Result = append(group.Result, r)
+			}
+		}
+	}
+	stat := statisticCompute(cConf, []PingGroup{group}).PingStatisticList[0]
+	if ok {
+		if b.stats == nil {
+			b.stats = map[ComputeUnitPriceType]PingSatistic{}
+		}
+		b.stats[priceType] = stat
+	}
+	return stat
+}
+
+// matchPriceType tell whether the result is selected by the price type. threshold is not supported
+func matchPriceType(r PingResult, priceType ComputeUnitPriceType) bool {
+	switch priceType {
+	case NoComputeUnitPrice:
+		return r.ComputeUnitPrice == 0
+	case HasComputeUnitPrice:
+		return r.ComputeUnitPrice > 0
+	}
+	return true
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
@@ -19,6 +20,9 @@ type InfluxdbClient struct {
 	AccessToken    string
 	InfluxCloudURL string
 	Client         influxdb2.Client
+	pending        sync.WaitGroup // async writes not finished
+	pendingMutex   sync.Mutex     // guard closing and pending.Add against Flush
+	closing        bool           // Flush has begun. new async writes are dropped
 }
 
 // NewInfluxdbClient Create a new InfluxClient
@@ -39,6 +43,7 @@ func (i *InfluxdbClient) PrepareInfluxdbData(r PingResult) *influxdb2write.Point
 		map[string]interface{}{
 			"hostname":             r.Hostname,
 			"compute_unit_price":   int64(r.ComputeUnitPrice),
+			"fee_strategy":         r.FeeStrategy,
 			"request_compute_unit": int64(r.RequestComputeUnits),
 			"submit":               r.Submitted,
 			"confirmed":            r.Confirmed,
@@ -48,6 +53,9 @@ func (i *InfluxdbClient) PrepareInfluxdbData(r PingResult) *influxdb2write.Point
 			"mean":                 r.Mean,
 			"stddev":               r.Stddev,
 			"take_time":            r.TakeTime,
+			"p50":                  r.P50,
+			"p90":                  r.P90,
+			"p99":                  r.P99,
 			"error":                r.Error,
 		},
 		time.Now())
@@ -59,7 +67,16 @@ func (i *InfluxdbClient) SendDatapointAsync(p *influxdb2write.Point) {
 		log.Println("ERROR! InfluxClient has not initiated!")
 		return
 	}
+	i.pendingMutex.Lock()
+	if i.closing {
+		i.pendingMutex.Unlock()
+		log.Println("Influxdb is closing, drop the datapoint")
+		return
+	}
+	i.pending.Add(1)
+	i.pendingMutex.Unlock()
 	go func() {
+		defer i.pending.Done()
 		writeAPI := i.Client.WriteAPIBlocking(i.Organization, i.Bucket)
 		err := writeAPI.WritePoint(context.Background(), p)
 		if err != nil {
@@ -69,6 +86,25 @@ func (i *InfluxdbClient) SendDatapointAsync(p *influxdb2write.Point) {
 	}()
 }
 
+// Flush wait for async writes to finish until timeout. Return false if timeout.
+// Async writes after Flush begins are dropped
+func (i *InfluxdbClient) Flush(timeout time.Duration) bool {
+	i.pendingMutex.Lock()
+	i.closing = true
+	i.pendingMutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		i.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 // ClientClose close Client connection
 func (i *InfluxdbClient) ClientClose() {
 	i.Client.Close()
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"syscall"
 
 	_ "github.com/GoogleCloudPlatform/cloudsql-proxy/proxy/dialers/postgres"
-	"github.com/blocto/solana-go-sdk/rpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -32,9 +36,7 @@ const (
 
 var influxdb *InfluxdbClient
 var userInputClusterMode string
-var mainnetFailover RPCFailover
-var testnetFailover RPCFailover
-var devnetFailover RPCFailover
+var clusterFailovers = map[Cluster]*RPCFailover{}
 
 const (
 	RunMainnetBeta ClustersToRun = "mainnet"
@@ -43,6 +45,44 @@ const (
 	RunAllClusters               = "all"
 )
 
+// Clusters return the clusters to run. c is "all" or names of clusters separated by comma
+func (c ClustersToRun) Clusters() []Cluster {
+	ret := []Cluster{}
+	for _, cConf := range config.Clusters {
+		if c == RunAllClusters {
+			ret = append(ret, cConf.Cluster)
+			continue
+		}
+		for _, name := range strings.Split(string(c), ",") {
+			if strings.TrimSpace(name) == cConf.Name {
+				ret = append(ret, cConf.Cluster)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Validate check every name in c is a configured cluster
+func (c ClustersToRun) Validate() error {
+	if c == RunAllClusters {
+		return nil
+	}
+	for _, name := range strings.Split(string(c), ",") {
+		found := false
+		for _, cConf := range config.Clusters {
+			if strings.TrimSpace(name) == cConf.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", ErrInvalidCluster, name)
+		}
+	}
+	return nil
+}
+
 func init() {
 	config = loadConfig()
 	log.Println(" *** Config Start *** ")
@@ -52,28 +92,15 @@ func init() {
 	log.Println(config.InfluxdbConfig)
 	log.Println("--- //// Retension --- ")
 	log.Println(config.Retension)
-	log.Println("--- //// ClusterCLIConfig--- ")
-	log.Println("ClusterCLIConfig Mainnet", config.ClusterCLIConfig.ConfigMain)
-	log.Println("ClusterCLIConfig Testnet", config.ClusterCLIConfig.ConfigTestnet)
-	log.Println("ClusterCLIConfig Devnet", config.ClusterCLIConfig.ConfigDevnet)
-	log.Println("--- Mainnet Ping  --- ")
-	log.Println("Mainnet.ClusterPing.APIServer", config.Mainnet.ClusterPing.APIServer)
-	log.Println("Mainnet.ClusterPing.PingServiceEnabled", config.Mainnet.ClusterPing.PingServiceEnabled)
-	log.Println("Mainnet.ClusterPing.AlternativeEnpoint.HostList", config.Mainnet.ClusterPing.AlternativeEnpoint.HostList)
-	log.Println("Mainnet.ClusterPing.PingConfig", config.Mainnet.ClusterPing.PingConfig)
-	log.Println("Mainnet.ClusterPing.Report", config.Mainnet.ClusterPing.Report)
-	log.Println("--- Testnet Ping  --- ")
-	log.Println("Mainnet.ClusterPing.APIServer", config.Testnet.ClusterPing.APIServer)
-	log.Println("Mainnet.ClusterPing.PingServiceEnabled", config.Mainnet.ClusterPing.PingServiceEnabled)
-	log.Println("Testnet.ClusterPing.AlternativeEnpoint.HostList", config.Testnet.ClusterPing.AlternativeEnpoint.HostList)
-	log.Println("Testnet.ClusterPing.PingConfig", config.Testnet.ClusterPing.PingConfig)
-	log.Println("Testnet.ClusterPing.Report", config.Testnet.ClusterPing.Report)
-	log.Println("--- Devnet Ping  --- ")
-	log.Println("Devnet.ClusterPing.APIServer", config.Devnet.ClusterPing.APIServer)
-	log.Println("Devnet.ClusterPing.Enabled", config.Devnet.ClusterPing.PingServiceEnabled)
-	log.Println("Devnet.ClusterPing.AlternativeEnpoint.HostList", config.Devnet.ClusterPing.AlternativeEnpoint.HostList)
-	log.Println("Devnet.ClusterPing.PingConfig", config.Devnet.ClusterPing.PingConfig)
-	log.Println("Devnet.ClusterPing.Report", config.Devnet.ClusterPing.Report)
+	for _, cConf := range config.Clusters {
+		log.Println("--- ", cConf.Name, " Ping (", cConf.Cluster, " /", cConf.Route, ") --- ")
+		log.Println(cConf.Name, ".ClusterCLIConfig", cConf.CLIConfig)
+		log.Println(cConf.Name, ".ClusterPing.APIServer", cConf.ClusterPing.APIServer)
+		log.Println(cConf.Name, ".ClusterPing.PingServiceEnabled", cConf.ClusterPing.PingServiceEnabled)
+		log.Println(cConf.Name, ".ClusterPing.AlternativeEnpoint.HostList", cConf.ClusterPing.AlternativeEnpoint.HostList)
+		log.Println(cConf.Name, ".ClusterPing.PingConfig", cConf.ClusterPing.PingConfig)
+		log.Println(cConf.Name, ".ClusterPing.Report", cConf.ClusterPing.Report)
+	}
 
 	log.Println(" *** Config End *** ")
 
@@ -100,63 +127,80 @@ func init() {
 		database = gormDB
 	}
 	log.Println("database connected")
+	if err := migrateDatabase(); err != nil {
+		log.Panic("migrate database error:", err)
+	}
 	if config.InfluxdbConfig.Enabled {
 		influxdb = NewInfluxdbClient(config.InfluxdbConfig)
 	}
 	/// ---- Start RPC Failover ---
 	log.Println("RPC Endpoint Failover Setting ---")
-	if len(config.Mainnet.AlternativeEnpoint.HostList) <= 0 {
-		mainnetFailover = NewRPCFailover([]RPCEndpoint{{
-			Endpoint: rpc.MainnetRPCEndpoint,
-			Piority:  1,
-			MaxRetry: 30}})
-	} else {
-		mainnetFailover = NewRPCFailover(config.Mainnet.AlternativeEnpoint.HostList)
-	}
-	if len(config.Testnet.AlternativeEnpoint.HostList) <= 0 {
-		testnetFailover = NewRPCFailover([]RPCEndpoint{{
-			Endpoint: rpc.MainnetRPCEndpoint,
-			Piority:  1,
-			MaxRetry: 30}})
-	} else {
-		testnetFailover = NewRPCFailover(config.Testnet.AlternativeEnpoint.HostList)
-	}
-	if len(config.Devnet.AlternativeEnpoint.HostList) <= 0 {
-		devnetFailover = NewRPCFailover([]RPCEndpoint{{
-			Endpoint: rpc.MainnetRPCEndpoint,
-			Piority:  1,
-			MaxRetry: 30}})
-	} else {
-		devnetFailover = NewRPCFailover(config.Devnet.AlternativeEnpoint.HostList)
+	for _, cConf := range config.Clusters {
+		hostList := cConf.AlternativeEnpoint.HostList
+		if len(hostList) <= 0 {
+			hostList = []RPCEndpoint{{
+				Endpoint: cConf.DefaultRPCEndpoint(),
+				Piority:  1,
+				MaxRetry: 30}}
+		}
+		failover := NewRPCFailover(hostList)
+		if len(failover.Endpoints) == 0 {
+			log.Panic(cConf.Name, " has no rpc endpoint")
+		}
+		clusterFailovers[cConf.Cluster] = &failover
 	}
 }
 
 func main() {
-	defer func() {
-		if influxdb != nil {
-			influxdb.ClientClose()
-		}
-		if database != nil {
-			sqldb, err := database.DB()
-			if err == nil {
-				sqldb.Close()
-			}
-		}
-	}()
 	flag.Parse()
 	clustersToRun := flag.Arg(0)
-	if !(strings.Compare(clustersToRun, string(RunMainnetBeta)) == 0 ||
-		strings.Compare(clustersToRun, string(RunTestnet)) == 0 ||
-		strings.Compare(clustersToRun, string(RunDevnet)) == 0 ||
-		strings.Compare(clustersToRun, string(RunAllClusters)) == 0) {
-		go launchWorkers(RunMainnetBeta)
-		go APIService(RunMainnetBeta)
-	} else {
-		go launchWorkers(ClustersToRun(clustersToRun))
-		go APIService(ClustersToRun(clustersToRun))
+	if len(clustersToRun) == 0 {
+		clustersToRun = string(RunMainnetBeta)
+	}
+	if err := ClustersToRun(clustersToRun).Validate(); err != nil {
+		log.Fatal(err)
 	}
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	startWorkers(workerCtx, ClustersToRun(clustersToRun))
+	servers := APIService(ClustersToRun(clustersToRun))
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for s := range sig {
+		if s == syscall.SIGHUP {
+			reloadCertificates()
+			continue
+		}
+		log.Println("receive signal ", s, ". shutting down")
+		break
+	}
+	shutdown(servers, stopWorkers)
+}
 
-	for {
-		time.Sleep(10 * time.Second)
+// shutdown drain api servers, stop workers, flush influxdb writes and close the database
+func shutdown(servers []*http.Server, stopWorkers context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	shutdownServers(ctx, servers)
+	log.Println("api servers stopped")
+	stopWorkers()
+	// a ping in progress finishes its batch before the worker exits
+	if !waitWorkers(ShutdownTimeout) {
+		log.Println("workers stop timeout")
+	} else {
+		log.Println("workers stopped")
+	}
+	if influxdb != nil {
+		if !influxdb.Flush(ShutdownTimeout) {
+			log.Println("influxdb flush timeout")
+		}
+		influxdb.ClientClose()
+	}
+	if database != nil {
+		sqldb, err := database.DB()
+		if err == nil {
+			sqldb.Close()
+		}
 	}
+	log.Println("shutdown completed")
 }
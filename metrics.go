@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// MetricsNamespace is the prefix of all prometheus metrics
+const MetricsNamespace = "solana_ping"
+
+// prometheus metrics. Served by /metrics of the api server
+var (
+	metricTxSubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "transactions_submitted_total",
+		Help:      "Number of ping transactions submitted.",
+	}, []string{"cluster", "hostname"})
+	metricTxConfirmed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "transactions_confirmed_total",
+		Help:      "Number of ping transactions confirmed.",
+	}, []string{"cluster", "hostname"})
+	metricConfirmationLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "confirmation_latency_seconds",
+		Help:      "Take time of ping transactions from sending to confirmed.",
+		Buckets:   []float64{0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 7.5, 10, 15, 20, 30, 45, 60, 90, 120, 180},
+	}, []string{"cluster", "hostname"})
+	metricPingErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "errors_total",
+		Help:      "Number of ping errors by category.",
+	}, []string{"cluster", "hostname", "category"})
+	metricFailoverRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "failover_retries_total",
+		Help:      "Number of retryable errors counted against a failover endpoint.",
+	}, []string{"endpoint"})
+	metricFailoverSwitches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "failover_switches_total",
+		Help:      "Number of times a worker switched to the next failover endpoint.",
+	}, []string{"cluster", "endpoint"})
+	metricWebhookFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "webhook_send_failures_total",
+		Help:      "Number of failed slack/discord webhook posts.",
+	}, []string{"service"})
+)
+
+// recordPingMetrics export a PingResult to prometheus metrics
+func recordPingMetrics(r PingResult) {
+	metricTxSubmitted.WithLabelValues(r.Cluster, r.Hostname).Add(float64(r.Submitted))
+	metricTxConfirmed.WithLabelValues(r.Cluster, r.Hostname).Add(float64(r.Confirmed))
+	for _, ts := range r.TakeTimes {
+		if ts <= 0 {
+			continue
+		}
+		metricConfirmationLatency.WithLabelValues(r.Cluster, r.Hostname).Observe(float64(ts) / 1000)
+	}
+	for _, e := range r.Error {
+		metricPingErrors.WithLabelValues(r.Cluster, r.Hostname, PingResultError(e).Category()).Inc()
+	}
+}
@@ -12,9 +12,14 @@ type DataPoint1MinResultJSON struct {
 	Confirmed  int    `json:"confirmed"`
 	Loss       string `json:"loss"`
 	Mean       int    `json:"mean_ms"`
+	P50        int    `json:"p50_ms"`
+	P90        int    `json:"p90_ms"`
+	P99        int    `json:"p99_ms"`
 	TimeStamp  string `json:"ts"`
 	ErrorCount int    `json:"error_count"`
 	Error      string `json:"error"`
+	// compute unit price -> count of samples. only when a fee filter is used
+	FeeDistribution map[uint64]int `json:"fee_distribution,omitempty"`
 }
 
 // SlackText slack structure
@@ -54,7 +59,8 @@ func ErrorsToString(errs []string) (errsString string) {
 
 func To1MinWindowJson(r *PingResult) DataPoint1MinResultJSON {
 	// Check result
-	jsonResult := DataPoint1MinResultJSON{Submitted: r.Submitted, Confirmed: r.Confirmed, Mean: int(r.Mean), Error: ErrorsToString(r.Error)}
+	jsonResult := DataPoint1MinResultJSON{Submitted: r.Submitted, Confirmed: r.Confirmed, Mean: int(r.Mean),
+		P50: int(r.P50), P90: int(r.P90), P99: int(r.P99), Error: ErrorsToString(r.Error)}
 	loss := fmt.Sprintf("%3.1f%s", r.Loss, "%")
 	jsonResult.Loss = loss
 	ts := time.Unix(r.TimeStamp, 0)
@@ -72,7 +78,9 @@ func PingResultToJson(stat *PingSatistic) DataPoint1MinResultJSON {
 		loss = fmt.Sprintf("%3.1f%s", float64(0), "%")
 	}
 
-	jsonResult := DataPoint1MinResultJSON{Submitted: int(stat.Submitted), Confirmed: int(stat.Confirmed), Mean: int(mean), ErrorCount: int(len(stat.Errors)), Error: errorShow}
+	jsonResult := DataPoint1MinResultJSON{Submitted: int(stat.Submitted), Confirmed: int(stat.Confirmed), Mean: int(mean),
+		P50: int(stat.TimeStatistic.P50), P90: int(stat.TimeStatistic.P90), P99: int(stat.TimeStatistic.P99),
+		ErrorCount: int(len(stat.Errors)), Error: errorShow}
 
 	jsonResult.Loss = loss
 	ts := time.Unix(stat.TimeStamp, 0).UTC()
@@ -83,11 +91,13 @@ func PingResultToJson(stat *PingSatistic) DataPoint1MinResultJSON {
 // ReportPayload get the report within specified minutes
 func (s *SlackPayload) ReportPayload(c Cluster, data *GroupsAllStatistic, globalSatistic GlobalStatistic, hideKeywords []string, messageMemo string) {
 	// Header Block
-	headerText := fmt.Sprintf("total-submitted: %3.0f, total-confirmed:%3.0f, average-loss:%3.1f%s\n memo:%s",
+	headerText := fmt.Sprintf("total-submitted: %3.0f, total-confirmed:%3.0f, average-loss:%3.1f%s\n memo:%s\n stages(mean/p90 ms):%s\n landed-slot(mean slots):%s",
 		globalSatistic.Submitted,
 		globalSatistic.Confirmed,
 		globalSatistic.Loss*100, "%",
-		messageMemo)
+		messageMemo,
+		stageStatisticText(globalSatistic.Stages),
+		slotStatisticText(globalSatistic.Slots))
 	header := Block{
 		BlockType: "section",
 		BlockText: SlackText{
@@ -99,7 +109,7 @@ func (s *SlackPayload) ReportPayload(c Cluster, data *GroupsAllStatistic, global
 	// BodyBlock
 	body := Block{}
 	records := reportRecordBlock(data)
-	description := "( Submitted, Confirmed, Loss, min/mean/max/stddev ms )"
+	description := "( Submitted, Confirmed, Loss, min/mean/max/stddev/p50/p90/p99 ms )"
 	//memo := "* rpc error : context deadline exceeded does not count as a transaction\n** BlockhashNotFound error does not show in Error List"
 	memo := "*BlockhashNotFound do not count as a transaction\n"
 	errorRecords := reportErrorBlock(data, hideKeywords)
@@ -108,15 +118,17 @@ func (s *SlackPayload) ReportPayload(c Cluster, data *GroupsAllStatistic, global
 		BlockText: SlackText{SType: "mrkdwn", SText: fmt.Sprintf("```%s\n%s\n%s\n%s```", description, records, memo, errorRecords)},
 	}
 	s.Blocks = append(s.Blocks, body)
+	if tiers := feeTierRecordBlock(data); len(tiers) > 0 {
+		s.Blocks = append(s.Blocks, Block{
+			BlockType: "section",
+			BlockText: SlackText{SType: "mrkdwn", SText: fmt.Sprintf("```%s```", tiers)},
+		})
+	}
 }
 
 func (s *SlackPayload) AlertPayload(conf ClusterConfig, gStat *GlobalStatistic, errorStistic map[string]int, thresholdAdj float64, hideKeywords []string, messageMemo string) {
-	var text, timeStatis string
-	if gStat.TimeStatistic.Stddev <= 0 {
-		timeStatis = fmt.Sprintf(" %d/%3.0f/%d/%s ", gStat.TimeStatistic.Min, gStat.TimeStatistic.Mean, gStat.TimeStatistic.Max, "NaN")
-	} else {
-		timeStatis = fmt.Sprintf(" %d/%3.0f/%d/%3.0f ", gStat.TimeStatistic.Min, gStat.TimeStatistic.Mean, gStat.TimeStatistic.Max, gStat.TimeStatistic.Stddev)
-	}
+	var text string
+	timeStatis := timeStatisticText(gStat.TimeStatistic)
 	errsorStatis := ""
 	for k, v := range errorStistic {
 		if !PingResultError(k).IsInErrorList(AlertErrorExceptionList) {
@@ -127,7 +139,7 @@ func (s *SlackPayload) AlertPayload(conf ClusterConfig, gStat *GlobalStatistic,
 		errsorStatis = strings.ReplaceAll(errsorStatis, w, "")
 	}
 
-	text = fmt.Sprintf("{ hostname: %s, memo: %s ,submitted: %3.0f, confirmed:%3.0f, loss: %3.1f%s, confirmation: min/mean/max/stddev = %s, next_threshold:%3.0f%s, error: %s}",
+	text = fmt.Sprintf("{ hostname: %s, memo: %s ,submitted: %3.0f, confirmed:%3.0f, loss: %3.1f%s, confirmation: min/mean/max/stddev/p50/p90/p99 = %s, next_threshold:%3.0f%s, error: %s}",
 		conf.HostName, messageMemo, gStat.Submitted, gStat.Confirmed, gStat.Loss*100, "%", timeStatis, thresholdAdj, "%", errsorStatis)
 
 	header := Block{
@@ -155,15 +167,57 @@ func (s *SlackPayload) FailoverAlertPayload(conf ClusterConfig, endpoint Failove
 	s.Blocks = append(s.Blocks, header)
 }
 
+// timeStatisticText format TimeStatistic into min/mean/max/stddev/p50/p90/p99
+func timeStatisticText(t TimeStatistic) string {
+	if t.Stddev <= 0 {
+		return fmt.Sprintf(" %d/%3.0f/%d/%s/%d/%d/%d ", t.Min, t.Mean, t.Max, "NaN", t.P50, t.P90, t.P99)
+	}
+	return fmt.Sprintf(" %d/%3.0f/%d/%3.0f/%d/%d/%d ", t.Min, t.Mean, t.Max, t.Stddev, t.P50, t.P90, t.P99)
+}
+
+// stageStatisticText format the mean/p90 of each stage which has data
+func stageStatisticText(stages [NumPingStages]TimeStatistic) string {
+	texts := []string{}
+	for i, t := range stages {
+		if t.Mean > 0 {
+			texts = append(texts, fmt.Sprintf("%s %3.0f/%d", PingStageNames[i], t.Mean, t.P90))
+		}
+	}
+	if len(texts) == 0 {
+		return " no data"
+	}
+	return " " + strings.Join(texts, ", ")
+}
+
+// slotStatisticText format the mean slot deltas of landed txs
+func slotStatisticText(slots SlotMeasure) string {
+	if slots.Count == 0 {
+		return " no data"
+	}
+	send, blockhash := slots.Mean()
+	return fmt.Sprintf(" since-send %.1f, since-blockhash %.1f", send, blockhash)
+}
+
+// feeTierRecordBlock format the statistic of each FeeLadder tier. empty if there is no tier
+func feeTierRecordBlock(data *GroupsAllStatistic) string {
+	if len(data.FeeTiers) == 0 {
+		return ""
+	}
+	text := "fee-ladder ( Price, Submitted, Confirmed, Loss, min/mean/max/stddev/p50/p90/p99 ms )\n"
+	for _, t := range data.FeeTiers {
+		if t.Count > 0 {
+			text = fmt.Sprintf("%s( %vml, %3.0f, %3.0f, %3.1f%s, %s )\n", text, t.ComputeUnitPrice, t.Submitted, t.Confirmed, t.Loss*100, "%", timeStatisticText(t.TimeStatistic))
+		} else {
+			text = fmt.Sprintf("%s( %vml, no data )\n", text, t.ComputeUnitPrice)
+		}
+	}
+	return text
+}
+
 func reportRecordBlock(data *GroupsAllStatistic) string {
 	text := ""
-	timeStatis := ""
 	for _, ps := range data.PingStatisticList {
-		if ps.TimeStatistic.Stddev <= 0 {
-			timeStatis = fmt.Sprintf(" %d/%3.0f/%d/%s ", ps.TimeStatistic.Min, ps.TimeStatistic.Mean, ps.TimeStatistic.Max, "NaN")
-		} else {
-			timeStatis = fmt.Sprintf(" %d/%3.0f/%d/%3.0f ", ps.TimeStatistic.Min, ps.TimeStatistic.Mean, ps.TimeStatistic.Max, ps.TimeStatistic.Stddev)
-		}
+		timeStatis := timeStatisticText(ps.TimeStatistic)
 		lossPercentage := ps.Loss * 100
 		if ps.Count > 0 {
 			text = fmt.Sprintf("%s( %3.0f, %3.0f, %3.1f%s, %s )\n", text, ps.Submitted, ps.Confirmed, lossPercentage, "%", timeStatis)
@@ -174,26 +228,26 @@ func reportRecordBlock(data *GroupsAllStatistic) string {
 
 // ReportPayload get the report within specified minutes
 func (s *DiscordPayload) ReportPayload(c Cluster, data *GroupsAllStatistic, globalSatistic GlobalStatistic, hideKeywords []string, messageMemo string) {
-	summary := fmt.Sprintf("**total-submitted: %3.0f  total-confirmed: %3.0f average-loss: %3.1f%s**\nmemo: %s",
+	summary := fmt.Sprintf("**total-submitted: %3.0f  total-confirmed: %3.0f average-loss: %3.1f%s**\nmemo: %s\nstages(mean/p90 ms): %s\nlanded-slot(mean slots): %s",
 		globalSatistic.Submitted,
 		globalSatistic.Confirmed,
 		globalSatistic.Loss*100, "%",
-		messageMemo)
-	header := "( Submitted, Confirmed, Loss, min/mean/max/stddev ms )"
+		messageMemo,
+		stageStatisticText(globalSatistic.Stages),
+		slotStatisticText(globalSatistic.Slots))
+	header := "( Submitted, Confirmed, Loss, min/mean/max/stddev/p50/p90/p99 ms )"
 	records := reportRecordBlock(data)
 	memo := "*BlockhashNotFound do not count as a transaction\n"
 	errorRecords := reportErrorBlock(data, hideKeywords)
 	s.Content = fmt.Sprintf("%s\n```%s\n%s\n%s\n%s```", summary, header, records, memo, errorRecords)
+	if tiers := feeTierRecordBlock(data); len(tiers) > 0 {
+		s.Content = fmt.Sprintf("%s\n```%s```", s.Content, tiers)
+	}
 }
 
 // AlertPayload get the report within specified minutes
 func (s *DiscordPayload) AlertPayload(conf ClusterConfig, gStat *GlobalStatistic, errorStistic map[string]int, thresholdAdj float64, hideKeywords []string, messageMemo string) {
-	var timeStatis string
-	if gStat.TimeStatistic.Stddev <= 0 {
-		timeStatis = fmt.Sprintf(" %d/%3.0f/%d/%s ", gStat.TimeStatistic.Min, gStat.TimeStatistic.Mean, gStat.TimeStatistic.Max, "NaN")
-	} else {
-		timeStatis = fmt.Sprintf(" %d/%3.0f/%d/%3.0f ", gStat.TimeStatistic.Min, gStat.TimeStatistic.Mean, gStat.TimeStatistic.Max, gStat.TimeStatistic.Stddev)
-	}
+	timeStatis := timeStatisticText(gStat.TimeStatistic)
 	errsorStatis := ""
 	for k, v := range errorStistic {
 		if !PingResultError(k).IsInErrorList(AlertErrorExceptionList) {
@@ -204,7 +258,7 @@ func (s *DiscordPayload) AlertPayload(conf ClusterConfig, gStat *GlobalStatistic
 		errsorStatis = strings.ReplaceAll(errsorStatis, w, "")
 	}
 
-	text := fmt.Sprintf("```{ hostname: %s, memo: %s, submitted: %3.0f, confirmed:%3.0f, loss: %3.1f%s, confirmation: min/mean/max/stddev = %s, next_threshold:%3.0f%s, error: %s}```",
+	text := fmt.Sprintf("```{ hostname: %s, memo: %s, submitted: %3.0f, confirmed:%3.0f, loss: %3.1f%s, confirmation: min/mean/max/stddev/p50/p90/p99 = %s, next_threshold:%3.0f%s, error: %s}```",
 		conf.HostName, messageMemo, gStat.Submitted, gStat.Confirmed, gStat.Loss*100, "%", timeStatis, thresholdAdj, "%", errsorStatis)
 	s.Content = text
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+)
+
+// stages of a ping tx. Processed, Confirmed and Finalized are measured since the tx is sent
+const (
+	StageBlockhash = iota // GetLatestBlockhash
+	StageSend             // SendTransaction acknowledgement
+	StageProcessed        // first seen processed
+	StageConfirmed        // first seen confirmed
+	StageFinalized        // first seen finalized. only if TrackFinalized is enabled
+	NumPingStages
+)
+
+// PingStageNames are the names of stages in API and reports
+var PingStageNames = [NumPingStages]string{"blockhash", "send", "processed", "confirmed", "finalized"}
+
+// PingStages is the take time (ms) of each stage. 0 means the stage is not reached
+type PingStages [NumPingStages]int64
+
+// StageTimer record the stages and slots of a ping tx
+type StageTimer struct {
+	Times         PingStages
+	BlockhashSlot uint64 // slot of the context of GetLatestBlockhash
+	SendSlot      uint64 // processed slot when the tx is sent. resolved from sendSlot by SlotDeltas
+	LandedSlot    uint64 // slot of the block which includes the tx. 0 means unknown
+	start         time.Time
+	sent          time.Time
+	sendSlot      <-chan uint64 // the slot fetched while the tx is sent
+}
+
+// Start is called before fetching the blockhash. It clears the stages of the last attempt
+func (s *StageTimer) Start() {
+	*s = StageTimer{start: time.Now()}
+}
+
+// BlockhashFetched is called after the blockhash is fetched
+func (s *StageTimer) BlockhashFetched(slot uint64) {
+	s.Times[StageBlockhash] = time.Since(s.start).Milliseconds()
+	s.BlockhashSlot = slot
+	s.start = time.Now()
+}
+
+// SendStart is called before the tx is sent. slot receives the processed slot, which is read after the tx lands
+// so fetching it is not in the measured time
+func (s *StageTimer) SendStart(slot <-chan uint64) {
+	s.sendSlot = slot
+	s.start = time.Now()
+}
+
+// Sent is called after the rpc node acknowledges the tx
+func (s *StageTimer) Sent() {
+	s.sent = time.Now()
+	s.Times[StageSend] = s.sent.Sub(s.start).Milliseconds()
+}
+
+// Seen is called when the status of the tx is fetched. Stages under the commitment which
+// have not been seen get the same time, because the status may skip them between two queries.
+func (s *StageTimer) Seen(commitment rpc.Commitment) {
+	if s.sent.IsZero() {
+		return
+	}
+	stage := StageProcessed
+	switch commitment {
+	case rpc.CommitmentConfirmed:
+		stage = StageConfirmed
+	case rpc.CommitmentFinalized:
+		stage = StageFinalized
+	}
+	t := time.Since(s.sent).Milliseconds()
+	if t <= 0 {
+		t = 1 // 0 means not reached
+	}
+	for i := StageProcessed; i <= stage; i++ {
+		if s.Times[i] == 0 {
+			s.Times[i] = t
+		}
+	}
+}
+
+// SlotDeltas return landed slot - send slot and landed slot - blockhash slot. ok is false if any slot is unknown
+func (s *StageTimer) SlotDeltas() (send int64, blockhash int64, ok bool) {
+	if s.LandedSlot != 0 && s.sendSlot != nil {
+		s.SendSlot = <-s.sendSlot
+		s.sendSlot = nil
+	}
+	if s.LandedSlot == 0 || s.SendSlot == 0 || s.BlockhashSlot == 0 {
+		return 0, 0, false
+	}
+	return int64(s.LandedSlot) - int64(s.SendSlot), int64(s.LandedSlot) - int64(s.BlockhashSlot), true
+}
+
+// SlotMeasure collect slot deltas of landed txs. Deltas can be 0, so Count tells whether there is data
+type SlotMeasure struct {
+	Count        int
+	SendSum      float64
+	BlockhashSum float64
+}
+
+// AddTx put the slot deltas of a tx into the measure
+func (m *SlotMeasure) AddTx(s *StageTimer) {
+	if send, blockhash, ok := s.SlotDeltas(); ok {
+		m.Count++
+		m.SendSum += float64(send)
+		m.BlockhashSum += float64(blockhash)
+	}
+}
+
+// AddResult put the slot deltas of a PingResult into the measure
+func (m *SlotMeasure) AddResult(r *PingResult) {
+	m.Count += r.SlotCount
+	m.SendSum += r.SendSlotDelta * float64(r.SlotCount)
+	m.BlockhashSum += r.BlockhashSlotDelta * float64(r.SlotCount)
+}
+
+// Merge put another measure into the measure
+func (m *SlotMeasure) Merge(other *SlotMeasure) {
+	m.Count += other.Count
+	m.SendSum += other.SendSum
+	m.BlockhashSum += other.BlockhashSum
+}
+
+// Mean return the mean slot deltas. 0 if there is no data
+func (m *SlotMeasure) Mean() (send float64, blockhash float64) {
+	if m.Count == 0 {
+		return 0, 0
+	}
+	return m.SendSum / float64(m.Count), m.BlockhashSum / float64(m.Count)
+}
+
+// StageMeasure collect the take time of each stage
+type StageMeasure [NumPingStages]TakeTime
+
+// Add put the stages which are reached into the measure
+func (m *StageMeasure) Add(stages PingStages) {
+	for i, t := range stages {
+		if t > 0 {
+			m[i].AddTime(t)
+		}
+	}
+}
+
+// Merge put all take time of another measure into the measure
+func (m *StageMeasure) Merge(other *StageMeasure) {
+	for i := range m {
+		m[i].Times = append(m[i].Times, other[i].Times...)
+	}
+}
+
+// Mean return the mean of each stage. 0 if a stage has no data
+func (m *StageMeasure) Mean() PingStages {
+	ret := PingStages{}
+	for i := range m {
+		_, mean, _, _, _ := m[i].Statistic()
+		ret[i] = int64(mean)
+	}
+	return ret
+}
+
+// TimeStatistic return the statistic of each stage
+func (m *StageMeasure) TimeStatistic() [NumPingStages]TimeStatistic {
+	ret := [NumPingStages]TimeStatistic{}
+	for i := range m {
+		ret[i] = m[i].TimeStatistic()
+	}
+	return ret
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/program/sysprog"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+// PingTxType is the kind of transaction which is sent by Ping
+type PingTxType string
+
+const (
+	TransferTx PingTxType = "transfer"  // system program transfer to Receiver
+	MemoTx     PingTxType = "memo"      // memo program
+	SPLTokenTx PingTxType = "spl-token" // token program transfer between associated token accounts of the fee payer and Receiver
+	CustomTx   PingTxType = "custom"    // an instruction of any program from CustomInstruction
+)
+
+// PingTx build the instructions of a ping transaction. Compute budget instructions are not included.
+// nonce is different between transactions so that transactions with the same blockhash are not duplicated.
+type PingTx interface {
+	Type() PingTxType
+	Instructions(feePayer common.PublicKey, nonce uint64) []types.Instruction
+}
+
+// NewPingTx create the PingTx of TxType in config. Default is TransferTx
+func NewPingTx(conf PingConfig) (PingTx, error) {
+	switch PingTxType(conf.TxType) {
+	case "", TransferTx:
+		receiver, err := parsePublicKey("Receiver", conf.Receiver)
+		if err != nil {
+			return nil, err
+		}
+		return transferPingTx{receiver: receiver}, nil
+	case MemoTx:
+		return memoPingTx{}, nil
+	case SPLTokenTx:
+		receiver, err := parsePublicKey("Receiver", conf.Receiver)
+		if err != nil {
+			return nil, err
+		}
+		mint, err := parsePublicKey("SPLToken.Mint", conf.SPLToken.Mint)
+		if err != nil {
+			return nil, err
+		}
+		to, _, err := common.FindAssociatedTokenAddress(receiver, mint)
+		if err != nil {
+			return nil, err
+		}
+		return splTokenPingTx{mint: mint, decimals: conf.SPLToken.Decimals, to: to}, nil
+	case CustomTx:
+		return newCustomPingTx(conf.CustomInstruction)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidTxType, conf.TxType)
+}
+
+func parsePublicKey(name string, key string) (common.PublicKey, error) {
+	pubkey := common.PublicKeyFromString(key)
+	if len(key) == 0 || pubkey.ToBase58() != key {
+		return common.PublicKey{}, fmt.Errorf("%w: %s %q", ErrInvalidPublicKey, name, key)
+	}
+	return pubkey, nil
+}
+
+type transferPingTx struct {
+	receiver common.PublicKey
+}
+
+func (t transferPingTx) Type() PingTxType {
+	return TransferTx
+}
+
+// Instructions transfer nonce lamports to the receiver
+func (t transferPingTx) Instructions(feePayer common.PublicKey, nonce uint64) []types.Instruction {
+	return []types.Instruction{
+		sysprog.Transfer(sysprog.TransferParam{
+			From:   feePayer,
+			To:     t.receiver,
+			Amount: nonce,
+		}),
+	}
+}
+
+type memoPingTx struct{}
+
+func (t memoPingTx) Type() PingTxType {
+	return MemoTx
+}
+
+// Instructions write a memo signed by the fee payer
+func (t memoPingTx) Instructions(feePayer common.PublicKey, nonce uint64) []types.Instruction {
+	return []types.Instruction{nonceMemo(feePayer, nonce)}
+}
+
+func nonceMemo(feePayer common.PublicKey, nonce uint64) types.Instruction {
+	return memo.BuildMemo(memo.BuildMemoParam{
+		SignerPubkeys: []common.PublicKey{feePayer},
+		Memo:          []byte("solana-ping " + strconv.FormatUint(nonce, 10)),
+	})
+}
+
+type splTokenPingTx struct {
+	mint     common.PublicKey
+	decimals uint8
+	to       common.PublicKey // associated token account of the receiver
+}
+
+func (t splTokenPingTx) Type() PingTxType {
+	return SPLTokenTx
+}
+
+// Instructions transfer nonce base units of the token from the associated token account of the fee payer.
+// Both associated token accounts must exist.
+func (t splTokenPingTx) Instructions(feePayer common.PublicKey, nonce uint64) []types.Instruction {
+	from, _, _ := common.FindAssociatedTokenAddress(feePayer, t.mint)
+	return []types.Instruction{
+		token.TransferChecked(token.TransferCheckedParam{
+			From:     from,
+			To:       t.to,
+			Mint:     t.mint,
+			Auth:     feePayer,
+			Amount:   nonce,
+			Decimals: t.decimals,
+		}),
+	}
+}
+
+type customPingTx struct {
+	programID common.PublicKey
+	accounts  []AccountMetaConfig
+	data      []byte
+}
+
+func newCustomPingTx(conf CustomInstructionConfig) (PingTx, error) {
+	programID, err := parsePublicKey("CustomInstruction.ProgramID", conf.ProgramID)
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range conf.Accounts {
+		if len(a.Pubkey) == 0 {
+			continue
+		}
+		if _, err := parsePublicKey(fmt.Sprintf("CustomInstruction.Accounts[%d]", i), a.Pubkey); err != nil {
+			return nil, err
+		}
+		if a.IsSigner { // the fee payer is the only signer
+			return nil, fmt.Errorf("%w: CustomInstruction.Accounts[%d] is a signer but not the fee payer", ErrInvalidTxConfig, i)
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(conf.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: CustomInstruction.Data is not base64", ErrInvalidTxConfig)
+	}
+	return customPingTx{programID: programID, accounts: conf.Accounts, data: data}, nil
+}
+
+func (t customPingTx) Type() PingTxType {
+	return CustomTx
+}
+
+// Instructions call the program with the configured accounts and data.
+// A memo with the nonce is appended because the instruction is the same in every transaction.
+func (t customPingTx) Instructions(feePayer common.PublicKey, nonce uint64) []types.Instruction {
+	accounts := make([]types.AccountMeta, 0, len(t.accounts))
+	for _, a := range t.accounts {
+		pubkey := feePayer
+		if len(a.Pubkey) > 0 {
+			pubkey = common.PublicKeyFromString(a.Pubkey)
+		}
+		accounts = append(accounts, types.AccountMeta{PubKey: pubkey, IsSigner: a.IsSigner, IsWritable: a.IsWritable})
+	}
+	return []types.Instruction{
+		{ProgramID: t.programID, Accounts: accounts, Data: t.data},
+		nonceMemo(feePayer, nonce),
+	}
+}
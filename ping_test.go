@@ -1,9 +1,34 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"golang.org/x/net/websocket"
 )
 
 var sch1 = PingResult{
@@ -45,6 +70,300 @@ func TestIsIdentical(t *testing.T) {
 	}
 }
 
+func TestGroupingWindow(t *testing.T) {
+	results := []PingResult{{TimeStamp: 100}, {TimeStamp: 160}, {TimeStamp: 161}, {TimeStamp: 400}, {TimeStamp: 30}}
+	groups := groupingWindow(results, 100, 400, 60)
+	if len(groups) != 5 {
+		t.Fatal("groups should be 5 but", len(groups))
+	}
+	if groups[0].TimeStamp != 400 || len(groups[0].Result) != 1 {
+		t.Fatal("group 0 is not correct", groups[0])
+	}
+	if groups[3].TimeStamp != 220 || len(groups[3].Result) != 1 || groups[3].Result[0].TimeStamp != 161 {
+		t.Fatal("group 3 is not correct", groups[3])
+	}
+	if groups[4].TimeStamp != 160 || len(groups[4].Result) != 1 || groups[4].Result[0].TimeStamp != 160 {
+		t.Fatal("group 4 is not correct", groups[4])
+	}
+	stat := statisticCompute(ClusterConfig{}, groups)
+	if stat.PingStatisticList[1].TimeStamp != 340 || stat.PingStatisticList[1].Window != 60 {
+		t.Fatal("empty group should keep its timestamp", stat.PingStatisticList[1])
+	}
+}
+
+func TestStatisticComputeStream(t *testing.T) {
+	results := []PingResult{
+		{TimeStamp: 30, Submitted: 10, Confirmed: 10, TakeTime: 1000},
+		{TimeStamp: 100, Submitted: 10, Confirmed: 9, TakeTime: 900},
+		{TimeStamp: 160, Submitted: 10, Confirmed: 8, TakeTime: 800, Error: pq.StringArray{"unknown error"}},
+		{TimeStamp: 161, Submitted: 10, Confirmed: 10, TakeTime: 700},
+		{TimeStamp: 162, Submitted: 10, Confirmed: 0, TakeTime: 600, Error: pq.StringArray{TooManyRequest429Text}},
+		{TimeStamp: 400, Submitted: 10, Confirmed: 7, TakeTime: 500},
+		{TimeStamp: 401, Submitted: 10, Confirmed: 7, TakeTime: 500},
+	}
+	want := statisticCompute(ClusterConfig{}, groupingWindow(results, 100, 400, 60))
+	got, err := statisticComputeStream(ClusterConfig{}, 100, 400, 60, eachResult(results))
+	if err != nil || got == nil {
+		t.Fatal("stream statistic should have data", err)
+	}
+	if len(got.PingStatisticList) != len(want.PingStatisticList) {
+		t.Fatal("groups are not the same", len(got.PingStatisticList), len(want.PingStatisticList))
+	}
+	for i := range want.PingStatisticList {
+		g, w := got.PingStatisticList[i], want.PingStatisticList[i]
+		if g.TimeStamp != w.TimeStamp || g.Count != w.Count || g.Submitted != w.Submitted || g.Confirmed != w.Confirmed ||
+			g.Loss != w.Loss || g.TimeStatistic != w.TimeStatistic || got.RawPingStaticList[i].Count != want.RawPingStaticList[i].Count {
+			t.Fatal("group", i, "is not the same", g, w)
+		}
+	}
+	if len(got.GlobalErrorStatistic) != 2 || got.GlobalErrorStatistic[TooManyRequest429Text] != 1 {
+		t.Fatal("errors are not the same", got.GlobalErrorStatistic)
+	}
+	if ret, err := statisticComputeStream(ClusterConfig{}, 100, 400, 60, eachResult(nil)); ret != nil || err != nil {
+		t.Fatal("stream statistic without results should be nil", ret, err)
+	}
+}
+
+func TestGroupPercentilesOfTxs(t *testing.T) {
+	cConf := ClusterConfig{ClusterPing: ClusterPing{PingConfig: PingConfig{TxTimeout: 100}}}
+	stages := func(confirmed ...int64) pq.Int64Array {
+		ret := pq.Int64Array{}
+		for _, c := range confirmed {
+			ret = append(ret, 10, 20, c/2, c, 0)
+		}
+		return ret
+	}
+	results := []PingResult{
+		// a batch of 4 txs. TakeTime is the sum of the batch
+		{TimeStamp: 60, Submitted: 4, Confirmed: 4, TakeTime: 10000, TxTimes: pq.Int64Array{1000, 2000, 3000, 4000}, TxStages: stages(1000, 2000, 3000, 4000)},
+		// a batch with a failed tx which is counted as a timeout
+		{TimeStamp: 60, Submitted: 2, Confirmed: 1, TakeTime: 5000, TxTimes: pq.Int64Array{5000}, TxStages: stages(5000), Error: pq.StringArray{"unknown error"}},
+		// a result stored before TxTimes
+		{TimeStamp: 60, Submitted: 1, Confirmed: 1, TakeTime: 6000, ConfirmedTime: 6000},
+	}
+	stat := statisticCompute(cConf, []PingGroup{{Result: results, TimeStamp: 60, Window: 60}}).PingStatisticList[0]
+	if len(stat.TimeMeasure.Times) != 7 || stat.TimeStatistic.Max != 100000 || stat.TimeStatistic.Min != 1000 {
+		t.Fatal("take times should be of each tx", stat.TimeMeasure.Times)
+	}
+	if stat.TimeStatistic.P50 < 3000 || stat.TimeStatistic.P50 > 4000 {
+		t.Fatal("p50 should be of txs", stat.TimeStatistic.P50)
+	}
+	confirmed := stat.StageMeasure.TimeStatistic()[StageConfirmed]
+	if len(stat.StageMeasure[StageConfirmed].Times) != 6 || confirmed.Max != 6000 || confirmed.Min != 1000 {
+		t.Fatal("stages should be of each tx", stat.StageMeasure[StageConfirmed].Times)
+	}
+	if len(stat.StageMeasure[StageFinalized].Times) != 0 {
+		t.Fatal("stages which are not reached should not be counted")
+	}
+}
+
+func TestParseRangeParams(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		err   error
+	}{
+		{"from=0&to=3600&step=1m", nil},
+		{"from=3600&to=0", ErrInvalidTimeRange},
+		{"from=0&to=3600&step=2m", ErrInvalidStep},
+		{fmt.Sprintf("from=0&to=%d&step=1m", (MaxRangeGroups+1)*60), ErrTooManyGroups},
+		{fmt.Sprintf("from=0&to=%d&step=1h", MaxAggregateRange+3600), ErrRangeTooLong},
+		{fmt.Sprintf("from=0&to=%d&step=1h", MaxAggregateRange), nil},
+	} {
+		c, _ := testGinContext("/devnet/range?" + tc.query)
+		_, _, _, err := parseRangeParams(c)
+		if err != tc.err {
+			t.Fatal(tc.query, "should return", tc.err, "but", err)
+		}
+	}
+}
+
+// eachResult feed results in the order of the slice, as forEachPingResult does with rows
+func eachResult(results []PingResult) func(func(*PingResult) error) error {
+	return func(fn func(*PingResult) error) error {
+		for i := range results {
+			if err := fn(&results[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func testGinContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	return c, w
+}
+
+// expectBadRequest run the handler and check it responds 400 with err
+func expectBadRequest(t *testing.T, handler gin.HandlerFunc, target string, err error) {
+	c, w := testGinContext(target)
+	handler(c)
+	body := struct{ Error string }{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusBadRequest || body.Error != err.Error() {
+		t.Fatal(target, "should be a bad request of", err, "but", w.Code, w.Body.String())
+	}
+}
+
+// withClusterParam run the handler with route as the :cluster param
+func withClusterParam(route string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Params = gin.Params{{Key: "cluster", Value: route}}
+		handler(c)
+	}
+}
+
+func TestPingStream(t *testing.T) {
+	saved := config.Clusters
+	defer func() { config.Clusters = saved }()
+	config.Clusters = []ClusterConfig{{Cluster: Devnet, Route: "devnet"}}
+	c, w := testGinContext("/unknown/stream")
+	withClusterParam("unknown", streamPingResult)(c)
+	if w.Code != http.StatusNotFound {
+		t.Fatal("unknown cluster should not be found", w.Code)
+	}
+
+	s := NewPingStream()
+	s.aggregating = true // groups are closed by the test instead of aggregateWorker
+	ch, ok := s.Subscribe(Devnet)
+	if !ok {
+		t.Fatal("subscribe should succeed")
+	}
+	next := func() (StreamEvent, bool) {
+		select {
+		case e := <-ch:
+			return e, true
+		default:
+			return StreamEvent{}, false
+		}
+	}
+	s.Publish(PingResult{Cluster: string(Devnet), TimeStamp: 61, Submitted: 10, Confirmed: 9})
+	s.Publish(PingResult{Cluster: string(Devnet), TimeStamp: 120, Submitted: 10, Confirmed: 10})
+	s.Publish(PingResult{Cluster: string(Testnet), TimeStamp: 100, Submitted: 10, Confirmed: 10})
+	for i := 0; i < 2; i++ {
+		if e, ok := next(); !ok || e.Event != StreamEventResult {
+			t.Fatal("each result of the cluster should be pushed", e)
+		}
+	}
+	if e, ok := next(); ok {
+		t.Fatal("results of other clusters should not be pushed", e)
+	}
+	s.closeGroups(119)
+	if e, ok := next(); ok {
+		t.Fatal("the group should not be closed before its end", e)
+	}
+	s.closeGroups(120)
+	e, ok := next()
+	if !ok || e.Event != StreamEventAggregate || e.Data.TimeStampUnix != 120 || e.Data.Submitted != 20 || e.Data.Confirmed != 19 {
+		t.Fatal("aggregate of the closed group is not correct", e)
+	}
+	s.Publish(PingResult{Cluster: string(Devnet), TimeStamp: 90, Submitted: 10, Confirmed: 0})
+	next()
+	s.closeGroups(240)
+	if e, ok := next(); ok {
+		t.Fatal("late results of a closed group should not be aggregated again", e)
+	}
+
+	subs := []chan StreamEvent{ch}
+	for i := 1; i < MaxStreamSubscribers; i++ {
+		sub, _ := s.Subscribe(Testnet)
+		subs = append(subs, sub)
+	}
+	if _, ok := s.Subscribe(Devnet); ok {
+		t.Fatal("subscribers should be limited by MaxStreamSubscribers")
+	}
+	s.Unsubscribe(Devnet, subs[0])
+	if _, ok := s.Subscribe(Devnet); !ok {
+		t.Fatal("subscribe should succeed after unsubscribe")
+	}
+}
+
+func TestExportParams(t *testing.T) {
+	saved := config.Clusters
+	defer func() { config.Clusters = saved }()
+	config.Clusters = []ClusterConfig{{Cluster: Devnet, Route: "devnet"}}
+	export := withClusterParam("devnet", exportPingResult)
+	for _, tc := range []struct {
+		query string
+		err   error
+	}{
+		{"format=xml", ErrInvalidExportFormat},
+		{"format=CSV", ErrInvalidExportFormat},
+		{"price=unknown", ErrInvalidPriceType},
+		{"price=threshold", ErrInvalidThreshold},
+		{"min_price=10&max_price=1", ErrInvalidPriceFilter},
+		{"format=ndjson&price_tier=-1", ErrInvalidPriceFilter},
+		{"fee_strategy=unknown", ErrInvalidFeeStrategyParam},
+		{"from=3600&to=0", ErrInvalidTimeRange},
+	} {
+		expectBadRequest(t, export, "/devnet/export?"+tc.query, tc.err)
+	}
+	c, _ := testGinContext("/devnet/export?from=0&to=3600&hostname=host&price=threshold&threshold=5&price_tier=1000&fee_strategy=ladder")
+	f, err := parseExportFilter(c, Devnet)
+	if err != nil || f.From != 0 || f.To != 3600 || f.Hostname != "host" || f.PriceType != ComputeUnitPriceThreshold || f.Threshold != 5 ||
+		f.PriceTier == nil || *f.PriceTier != 1000 || f.MinPrice != nil || f.FeeStrategy != string(LadderFee) {
+		t.Fatal("export filter is not correct", f, err)
+	}
+	if len(toExportRow(&PingResult{Error: []string{"a", "b"}}).csvRecord()) != len(exportCSVHeader) {
+		t.Fatal("csv record should match the header")
+	}
+}
+
+func TestFeeFilterParams(t *testing.T) {
+	saved := config.Clusters
+	defer func() { config.Clusters = saved }()
+	config.Clusters = []ClusterConfig{{Cluster: Devnet, Route: "devnet"}}
+	fees := withClusterParam("devnet", feeDistribution)
+	for _, tc := range []struct {
+		query string
+		err   error
+	}{
+		{fmt.Sprintf("from=0&to=%d", MaxAggregateRange+1), ErrRangeTooLong},
+		{"from=3600&to=0", ErrInvalidTimeRange},
+		{"from=0&to=3600&min_price=10&max_price=1", ErrInvalidPriceFilter},
+		{"from=0&to=3600&max_price=1.5", ErrInvalidPriceFilter},
+		{"from=0&to=3600&price_tier=tier", ErrInvalidPriceFilter},
+		{"from=0&to=3600&fee_strategy=unknown", ErrInvalidFeeStrategyParam},
+	} {
+		expectBadRequest(t, fees, "/devnet/fees?"+tc.query, tc.err)
+	}
+
+	c, _ := testGinContext("/devnet/fees?min_price=1&max_price=1&price_tier=0&fee_strategy=ladder")
+	f, err := parseFeeFilterParams(c, Devnet, NoComputeUnitPrice)
+	if err != nil || f.Cluster != Devnet || f.PingType != DataPoint1Min || f.PriceType != NoComputeUnitPrice ||
+		*f.MinPrice != 1 || *f.MaxPrice != 1 || f.PriceTier == nil || *f.PriceTier != 0 || f.FeeStrategy != string(LadderFee) {
+		t.Fatal("fee filter is not correct", f, err)
+	}
+	c, _ = testGinContext("/devnet/fees")
+	if f, err = parseFeeFilterParams(c, Devnet, AllData); err != nil || f.PriceType != AllData || f.MinPrice != nil || f.MaxPrice != nil || f.PriceTier != nil || f.FeeStrategy != "" {
+		t.Fatal("fee filter without query should be empty", f, err)
+	}
+}
+
+func TestTakeTimePercentiles(t *testing.T) {
+	timer := TakeTime{}
+	for i := int64(1); i <= 100; i++ {
+		timer.AddTime(i * 100)
+	}
+	timer.AddTime(0) // failed ping is not counted
+	p50, p90, p99 := timer.Percentiles()
+	if p50 < 4950 || p50 > 5050 {
+		t.Fatal("p50 is not correct", p50)
+	}
+	if p90 < 8950 || p90 > 9050 {
+		t.Fatal("p90 is not correct", p90)
+	}
+	if p99 < 9850 || p99 > 10000 {
+		t.Fatal("p99 is not correct", p99)
+	}
+	single := TakeTime{Times: []int64{777}}
+	if p50, _, p99 := single.Percentiles(); p50 != 777 || p99 != 777 {
+		t.Fatal("single value percentile is not correct", p50, p99)
+	}
+}
+
 // func TestParse(t *testing.T) {
 // 	pings := []PingResult{sch1}
 // 	avg := generateStatisticData(pings)
@@ -96,3 +415,738 @@ msg BD5heio2zqXdNuN1i7hia6dKbLVq7RvYt9HanYdj8nWD
 3 transactions submitted, 3 transactions confirmed, 0.0% transaction loss
 confirmation min/mean/max/stddev = 773/884/1106/192 ms
  `
+
+func TestHistoryCache(t *testing.T) {
+	h := NewHistoryCache()
+	now := time.Now().UTC().Unix()
+	if _, ok := h.Last6hours(Devnet, AllData); ok {
+		t.Fatal("cache should not be used before the first refresh")
+	}
+	h.Load(Devnet, (now-historyCacheKeep)/60*60, now, []PingResult{
+		{TimeStamp: now - 7*60*60, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 0},
+		{TimeStamp: now - 2*60*60, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 0},
+		{TimeStamp: now, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 10, ComputeUnitPrice: 100},
+		{TimeStamp: now, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 5},
+		{TimeStamp: now, Cluster: "Devnet", PingType: string(DataPointReport), Submitted: 10, Confirmed: 0},
+	})
+	stats, ok := h.Last6hours(Devnet, AllData)
+	if !ok || len(stats) != 6*60 {
+		t.Fatal("there should be 360 groups but", len(stats))
+	}
+	if stats[0].Count != 2 || stats[0].Submitted != 20 || stats[0].Confirmed != 15 {
+		t.Fatal("the latest group is not correct", stats[0])
+	}
+	if stats[120].Count != 1 || stats[120].Loss != 1 {
+		t.Fatal("the group of 2 hours ago is not correct", stats[120])
+	}
+	stats, _ = h.Last6hours(Devnet, HasComputeUnitPrice)
+	if stats[0].Count != 1 || stats[0].Confirmed != 10 {
+		t.Fatal("hasprice group is not correct", stats[0])
+	}
+	// a refresh reloads the last minutes, so loaded results are replaced instead of added twice
+	h.Load(Devnet, (now-historyCacheResync)/60*60, now, []PingResult{
+		{TimeStamp: now, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 10, ComputeUnitPrice: 100},
+		{TimeStamp: now, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 5},
+		{TimeStamp: now, Cluster: "Devnet", PingType: string(DataPoint1Min), Submitted: 10, Confirmed: 10, ComputeUnitPrice: 100, Hostname: "other-host"},
+	})
+	stats, _ = h.Last6hours(Devnet, HasComputeUnitPrice)
+	if stats[0].Count != 2 {
+		t.Fatal("cached statistic should be updated by a refresh", stats[0])
+	}
+	if stats, _ := h.Last6hours(Devnet, AllData); stats[0].Count != 3 || stats[120].Count != 1 {
+		t.Fatal("a refresh should keep earlier minutes", stats[0], stats[120])
+	}
+	if _, ok := h.Last6hours(Devnet, ComputeUnitPriceThreshold); ok {
+		t.Fatal("threshold should not be cached")
+	}
+}
+
+func TestHistoryCacheAlignment(t *testing.T) {
+	h := NewHistoryCache()
+	now := time.Now().UTC().Unix()
+	records := []PingResult{} // in time order as the database returns
+	for i, ago := range []int64{5*60*60 + 30, 3600, 125, 61, 60, 59, 1, 0} {
+		for j, host := range []string{"host-a", "host-b"} {
+			records = append(records, PingResult{TimeStamp: now - ago, Cluster: "Devnet", PingType: string(DataPoint1Min), Hostname: host,
+				Submitted: 10, Confirmed: 10 - i%3, TakeTime: int64(1000 + 100*i + j), ComputeUnitPrice: uint64(j * 100)})
+		}
+	}
+	h.Load(Devnet, (now-historyCacheKeep)/60*60, now, records)
+	for _, priceType := range []ComputeUnitPriceType{AllData, NoComputeUnitPrice, HasComputeUnitPrice} {
+		cached, _ := h.Last6hours(Devnet, priceType)
+		// the database path of GetLast6hours: the latest record of the price type and getRangeStatistic of the rows
+		matched := []PingResult{}
+		latest := int64(0)
+		for _, r := range records {
+			if matchPriceType(r, priceType) {
+				matched = append(matched, r)
+				latest = r.TimeStamp
+			}
+		}
+		end := last6hoursEnd(latest, time.Now().UTC().Unix())
+		if end%60 != 0 {
+			t.Fatal("groups should end at a minute", end)
+		}
+		groupsStat, _ := statisticComputeStream(ClusterConfig{}, end-6*60*60, end, 60, eachResult(matched))
+		db := groupsStat.PingStatisticList
+		if len(cached) != len(db) {
+			t.Fatal("cache and database should have the same groups", priceType, len(cached), len(db))
+		}
+		for i := range db {
+			c, d := cached[i], db[i]
+			if c.TimeStamp != d.TimeStamp || c.Count != d.Count || c.Submitted != d.Submitted || c.Confirmed != d.Confirmed ||
+				c.Loss != d.Loss || c.TimeStatistic != d.TimeStatistic || len(c.FeeDistribution) != len(d.FeeDistribution) {
+				t.Fatal("cache and database are not the same at", priceType, i, c, d)
+			}
+		}
+	}
+}
+
+func TestDashboardWindowCache(t *testing.T) {
+	now := time.Now().UTC().Unix()
+	historyCache.Load("dashboard-test", (now-historyCacheKeep)/60*60, now, []PingResult{
+		{TimeStamp: now, Cluster: "dashboard-test", PingType: string(DataPoint1Min), Submitted: 1, Confirmed: 1, ComputeUnitPrice: 100},
+	})
+	dc := dashboardWindowCache{windows: map[string]dashboardWindow{}}
+	w := dc.get(context.Background(), "dashboard-test", 6, 60)
+	if len(w.stats) != 6*60 || w.stats[0].Count != 1 || w.to != w.stats[0].TimeStamp || w.to-w.from != HistoryCacheWindow {
+		t.Fatal("6 hours window should be from historyCache", len(w.stats), w.from, w.to)
+	}
+	cached := dashboardWindow{stats: []PingSatistic{{Count: 7}}, from: 1, to: 2, expire: time.Now().Add(time.Minute)}
+	dc.windows["dashboard-test/24"] = cached
+	if w := dc.get(context.Background(), "dashboard-test", 24, 5*60); len(w.stats) != 1 || w.stats[0].Count != 7 {
+		t.Fatal("a window should be reused before it expires", w)
+	}
+}
+
+func TestAvailabilityOfWindow(t *testing.T) {
+	minutes := []minuteAvailability{
+		{TimeStamp: 60, Down: true}, // out of the window
+		{TimeStamp: 120, Down: false},
+		{TimeStamp: 180, Down: true},
+		{TimeStamp: 300, Down: true}, // 240 has no data
+		{TimeStamp: 360, Down: false},
+		{TimeStamp: 420, Down: true},
+	}
+	w := availabilityOfWindow(minutes, "test", 60, 480)
+	if w.MeasuredMinutes != 5 || w.NoDataMinutes != 2 || w.DowntimeMinutes != 3 {
+		t.Fatal("minutes are not correct", w)
+	}
+	if w.Availability == nil || *w.Availability != 40 {
+		t.Fatal("availability should be 40")
+	}
+	if w.LongestOutage == nil || w.LongestOutage.Minutes != 2 || w.LongestOutage.StartUnix != 120 || w.LongestOutage.EndUnix != 300 {
+		t.Fatal("longest outage is not correct", w.LongestOutage)
+	}
+	w = availabilityOfWindow(minutes, "test", 480, 600)
+	if w.Availability != nil || w.LongestOutage != nil || w.NoDataMinutes != 2 {
+		t.Fatal("window without data is not correct", w)
+	}
+}
+
+func writeTestCertificate(t *testing.T, crtPath string, keyPath string, cn string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(crtPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	crtPath, keyPath := filepath.Join(dir, "crt.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertificate(t, crtPath, keyPath, "first")
+	r, err := newCertReloader(crtPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.watch(); err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		cert, _ := r.GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+	if commonName() != "first" {
+		t.Fatal("first certificate is not loaded")
+	}
+	writeTestCertificate(t, crtPath, keyPath, "second")
+	for i := 0; i < 50 && commonName() != "second"; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if commonName() != "second" {
+		t.Fatal("certificate is not reloaded after files change")
+	}
+	os.WriteFile(crtPath, []byte("broken"), 0600)
+	reloadCertificates()
+	if commonName() != "second" {
+		t.Fatal("certificate should be kept if reload fails")
+	}
+}
+
+func TestClustersToRun(t *testing.T) {
+	saved := config.Clusters
+	defer func() { config.Clusters = saved }()
+	config.Clusters = []ClusterConfig{
+		{Cluster: MainnetBeta, Name: "mainnet", Route: "mainnet-beta"},
+		{Cluster: Testnet, Name: "testnet", Route: "testnet"},
+		{Cluster: "localnet", Name: "localnet", Route: "local"},
+	}
+	if clusters := ClustersToRun("all").Clusters(); len(clusters) != 3 {
+		t.Fatal("all should run 3 clusters but", clusters)
+	}
+	if clusters := ClustersToRun("localnet,mainnet").Clusters(); len(clusters) != 2 || clusters[0] != MainnetBeta || clusters[1] != "localnet" {
+		t.Fatal("subset is not correct", clusters)
+	}
+	if ClustersToRun("mainnet,unknown").Validate() == nil {
+		t.Fatal("unknown cluster should be invalid")
+	}
+	if c, ok := clusterFromParam("local"); !ok || c != "localnet" {
+		t.Fatal("route of localnet is not correct")
+	}
+	if clusterRouteName(MainnetBeta) != "mainnet-beta" {
+		t.Fatal("route name of MainnetBeta is not correct")
+	}
+}
+
+func TestReadClusterConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config-a.yaml"), []byte("PingServiceEnabled: true\nReport:\n Enabled: true\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "config-b.yaml"), []byte("APIServer:\n Enabled: true\n"), 0600)
+	a := readClusterConfig(dir, "", "a", "a", "a", "config-a", "")
+	b := readClusterConfig(dir, "", "b", "b", "b", "config-b", "")
+	if !a.PingServiceEnabled || !a.Report.Enabled {
+		t.Fatal("config of a is not read", a.ClusterPing)
+	}
+	if b.PingServiceEnabled || b.Report.Enabled || !b.APIServer.Enabled {
+		t.Fatal("b should not inherit settings of a", b.ClusterPing)
+	}
+	if builtin := readClusterConfig(dir, "", Testnet, "testnet", "testnet", "", ""); builtin.PingServiceEnabled || builtin.DefaultRPCEndpoint() != rpc.TestnetRPCEndpoint {
+		t.Fatal("testnet without a config file should have default settings", builtin.ClusterPing)
+	}
+	if (ClusterConfig{Cluster: Devnet}).DefaultRPCEndpoint() != rpc.DevnetRPCEndpoint {
+		t.Fatal("devnet should use its own default endpoint")
+	}
+	custom := ClusterConfig{Cluster: "localnet", CLIConfig: SolanaCLIConfig{JsonRPCURL: "http://127.0.0.1:8899"}}
+	if custom.DefaultRPCEndpoint() != "http://127.0.0.1:8899" {
+		t.Fatal("a config-defined cluster should use json_rpc_url")
+	}
+}
+
+func TestOpenAPIV2Document(t *testing.T) {
+	doc, err := openAPIV2Document([]string{"mainnet-beta", "local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := struct {
+		Components struct {
+			Parameters struct {
+				Cluster struct {
+					Schema struct {
+						Enum []string
+					}
+				}
+			}
+		}
+		Paths map[string]interface{}
+	}{}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if enum := spec.Components.Parameters.Cluster.Schema.Enum; len(enum) != 2 || enum[1] != "local" {
+		t.Fatal("cluster enum should be the configured clusters", enum)
+	}
+	if _, ok := spec.Paths["/v2/{cluster}/range"]; !ok {
+		t.Fatal("paths should be kept")
+	}
+}
+
+func TestCompareClusterPoint(t *testing.T) {
+	base := PingSatistic{Count: 1, Loss: 0.1, TimeStatistic: TimeStatistic{Mean: 1000, P90: 1500}}
+	stat := PingSatistic{Count: 1, Loss: 0.3, TimeStatistic: TimeStatistic{Mean: 1500, P90: 1200}}
+	p := compareClusterPoint(&stat, &base, false)
+	if p.LossDelta == nil || *p.LossDelta < 0.199 || *p.LossDelta > 0.201 || *p.LatencyMeanDelta != 500 || *p.LatencyP90Delta != -300 {
+		t.Fatal("deltas are not correct", p)
+	}
+	if p := compareClusterPoint(&base, &base, true); p.LossDelta != nil {
+		t.Fatal("baseline should not have deltas")
+	}
+	if p := compareClusterPoint(&PingSatistic{}, &base, false); p.LossDelta != nil {
+		t.Fatal("a group without data should not have deltas")
+	}
+}
+
+func TestCompareClustersParams(t *testing.T) {
+	saved := config.Clusters
+	defer func() { config.Clusters = saved }()
+	config.Clusters = []ClusterConfig{
+		{Cluster: MainnetBeta, Route: "mainnet-beta"},
+		{Cluster: Testnet, Route: "testnet"},
+		{Cluster: Devnet, Route: "devnet"},
+	}
+	for _, tc := range []struct {
+		query string
+		err   error
+	}{
+		{"clusters=mainnet-beta", ErrInvalidCompareClusters},
+		{"clusters=mainnet-beta,mainnet-beta", ErrInvalidCompareClusters},
+		{"clusters=mainnet-beta,unknown", ErrInvalidCompareClusters},
+		{"clusters=mainnet-beta,testnet,devnet,mainnet-beta,testnet,devnet", nil},
+		{"clusters=mainnet-beta,testnet&baseline=devnet", ErrInvalidBaseline},
+		{"clusters=mainnet-beta,testnet&from=3600&to=0", ErrInvalidTimeRange},
+		{fmt.Sprintf("clusters=mainnet-beta,testnet&from=0&to=%d&step=1h", MaxAggregateRange+3600), ErrRangeTooLong},
+		{"clusters=mainnet-beta,testnet&min_price=2&max_price=1", ErrInvalidPriceFilter},
+	} {
+		if tc.err == nil { // valid clusters, stop before querying the database
+			tc.query, tc.err = tc.query+"&step=2m", ErrInvalidStep
+		}
+		expectBadRequest(t, compareClusters, "/compare?"+tc.query, tc.err)
+	}
+	config.Clusters = append(config.Clusters, ClusterConfig{Cluster: "a", Route: "a"}, ClusterConfig{Cluster: "b", Route: "b"}, ClusterConfig{Cluster: "c", Route: "c"})
+	expectBadRequest(t, compareClusters, "/compare?clusters=mainnet-beta,testnet,devnet,a,b,c", ErrInvalidCompareClusters)
+}
+
+func TestFailoverSwitch(t *testing.T) {
+	f := NewRPCFailover([]RPCEndpoint{{Endpoint: "http://a", Piority: 1, MaxRetry: 2}, {Endpoint: "http://b", Piority: 2, MaxRetry: 2, AccessToken: "secret"}})
+	cConf := ClusterConfig{Cluster: Devnet}
+	idx1, idx2 := 0, 0
+	c1 := f.GoNext(nil, &idx1, cConf, 0)
+	c2 := f.GoNext(nil, &idx2, cConf, 1)
+	if f.GoNext(c1, &idx1, cConf, 0) != c1 {
+		t.Fatal("client should be kept if the endpoint does not fail")
+	}
+	f.EndpointAt(idx1).RetryResult(PingResultError(ServiceUnavilable503Text))
+	f.EndpointAt(idx1).RetryResult(PingResultError(ServiceUnavilable503Text))
+	if f.GoNext(c1, &idx1, cConf, 0) == c1 || idx1 != 1 {
+		t.Fatal("worker should switch to next endpoint")
+	}
+	if f.GoNext(c2, &idx2, cConf, 1) == c2 || idx2 != 1 {
+		t.Fatal("other workers should follow the switch")
+	}
+	if e, err := f.SwitchTo(0); err != nil || f.Endpoints[0].Retry != 0 || e.Endpoint != f.Endpoints[0].Endpoint {
+		t.Fatal("switch should reset retry and return the endpoint", err)
+	}
+	f.GoNext(c1, &idx1, cConf, 0)
+	if idx1 != 0 {
+		t.Fatal("worker should follow the admin switch")
+	}
+	if _, err := f.SwitchTo(2); err == nil {
+		t.Fatal("index out of range should fail")
+	}
+	state := FailoverStateToJson(Devnet, &f)
+	if !state.Endpoints[1].HasAccessToken || state.Endpoints[1].LastError != "" || state.Endpoints[0].LastError == "" || !state.Endpoints[0].Active {
+		t.Fatal("state is not correct", state)
+	}
+}
+
+func TestFailoverSlackUnlocked(t *testing.T) {
+	f := NewRPCFailover([]RPCEndpoint{{Endpoint: "http://a", Piority: 1, MaxRetry: 1}, {Endpoint: "http://b", Piority: 2, MaxRetry: 1}})
+	snapshot := make(chan int, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the failover state must be readable while the alert is being sent
+		go func() {
+			index, _ := f.Snapshot()
+			snapshot <- index
+		}()
+		select {
+		case <-snapshot:
+		case <-time.After(time.Second):
+			t.Error("failover lock is held while sending the slack alert")
+		}
+	}))
+	defer webhook.Close()
+	cConf := ClusterConfig{Cluster: Devnet}
+	cConf.AlternativeEnpoint.SlackAlert = EndpointAlert{Enabled: true, Webhook: webhook.URL}
+	idx := 0
+	c := f.GoNext(nil, &idx, cConf, 0)
+	f.EndpointAt(idx).RetryResult(PingResultError(ServiceUnavilable503Text))
+	if f.GoNext(c, &idx, cConf, 0) == c || idx != 1 {
+		t.Fatal("worker should switch to next endpoint")
+	}
+}
+
+func TestAlertEvent(t *testing.T) {
+	trigger := NewAlertTriggerByParams("test", "", 20)
+	cConf := ClusterConfig{Cluster: Devnet, HostName: "host"}
+	trigger.Update(0.6)
+	if !trigger.ShouldAlertSend() {
+		t.Fatal("alert should be sent when loss is over threshold")
+	}
+	e := AlertEventToJson(trigger.NewAlertEvent(cConf, "memo"))
+	if e.OldLevel != 0 || e.NewLevel != 2 || e.OldThreshold != 20 || e.NewThreshold != 75 || e.Direction != "up" || e.Trigger != "test" {
+		t.Fatal("up event is not correct", e)
+	}
+	trigger.Update(0.3)
+	if !trigger.ShouldAlertSend() {
+		t.Fatal("alert should be sent when loss is down a level")
+	}
+	e = AlertEventToJson(trigger.NewAlertEvent(cConf, "memo"))
+	if e.OldLevel != 2 || e.NewLevel != 1 || e.Direction != "down" {
+		t.Fatal("down event is not correct", e)
+	}
+	always := NewAlertTriggerByParams("always", "", 0)
+	always.Update(0.6)
+	if !always.ShouldAlertSend() || always.LevelChanged() {
+		t.Fatal("always send mode should send without changing level")
+	}
+}
+
+func TestNewPingTx(t *testing.T) {
+	receiver := "9qT3WeLV5o3t3GVgCk9A3mpTRjSb9qBvnfrAsVKLhmU5"
+	payer := common.PublicKeyFromString("5Ljr6vSNeX3jEvfbVNJGjGP4HwqfKtdePKrQsVYWSsth")
+	for _, txType := range []PingTxType{"", TransferTx, MemoTx} {
+		tx, err := NewPingTx(PingConfig{Receiver: receiver, TxType: string(txType)})
+		if err != nil || len(tx.Instructions(payer, 1)) != 1 {
+			t.Fatal(txType, " tx is not correct", err)
+		}
+	}
+	if _, err := NewPingTx(PingConfig{Receiver: receiver, TxType: "unknown"}); !errors.Is(err, ErrInvalidTxType) {
+		t.Fatal("unknown tx type should fail", err)
+	}
+	if _, err := NewPingTx(PingConfig{Receiver: receiver, TxType: string(SPLTokenTx), SPLToken: SPLTokenConfig{Mint: "bad"}}); !errors.Is(err, ErrInvalidPublicKey) {
+		t.Fatal("invalid mint should fail", err)
+	}
+	custom := CustomInstructionConfig{
+		ProgramID: common.MemoProgramID.ToBase58(),
+		Accounts:  []AccountMetaConfig{{IsSigner: true, IsWritable: true}, {Pubkey: receiver}},
+		Data:      base64.StdEncoding.EncodeToString([]byte("hello")),
+	}
+	tx, err := NewPingTx(PingConfig{TxType: string(CustomTx), CustomInstruction: custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := tx.Instructions(payer, 7)
+	if len(ins) != 2 || ins[0].Accounts[0].PubKey != payer || !ins[0].Accounts[0].IsSigner || string(ins[0].Data) != "hello" || string(ins[1].Data) != "solana-ping 7" {
+		t.Fatal("custom tx is not correct", ins)
+	}
+	custom.Accounts[1].IsSigner = true
+	if _, err := NewPingTx(PingConfig{TxType: string(CustomTx), CustomInstruction: custom}); !errors.Is(err, ErrInvalidTxConfig) {
+		t.Fatal("signer other than fee payer should fail", err)
+	}
+}
+
+func TestStageTimer(t *testing.T) {
+	s := StageTimer{}
+	s.Start()
+	s.Seen(rpc.CommitmentConfirmed) // not sent yet
+	if s.Times[StageConfirmed] != 0 {
+		t.Fatal("stages should not be seen before the tx is sent")
+	}
+	s.BlockhashFetched(100)
+	if _, _, ok := s.SlotDeltas(); ok {
+		t.Fatal("slot deltas should be unknown before the tx lands")
+	}
+	sendSlot := make(chan uint64, 1)
+	sendSlot <- 102
+	s.SendStart(sendSlot)
+	s.Sent()
+	s.Seen(rpc.CommitmentConfirmed)
+	s.LandedSlot = 102
+	if send, blockhash, ok := s.SlotDeltas(); !ok || send != 0 || blockhash != 2 {
+		t.Fatal("slot deltas are not correct", send, blockhash)
+	}
+	if s.Times[StageProcessed] <= 0 || s.Times[StageProcessed] != s.Times[StageConfirmed] || s.Times[StageFinalized] != 0 {
+		t.Fatal("skipped stages should get the time of the seen stage", s.Times)
+	}
+	m := StageMeasure{}
+	m.Add(PingStages{100, 20, 0, 0, 0})
+	m.Add(PingStages{300, 40, 500, 1000, 0})
+	mean := m.Mean()
+	if mean != (PingStages{200, 30, 500, 1000, 0}) {
+		t.Fatal("mean should ignore stages which are not reached", mean)
+	}
+	r := PingResult{}
+	r.SetStages(mean)
+	if r.Stages() != mean || r.ConfirmedTime != 1000 {
+		t.Fatal("stages of PingResult are not correct", r)
+	}
+	slots := SlotMeasure{}
+	slots.AddTx(&s)
+	slots.AddResult(&PingResult{SlotCount: 3, SendSlotDelta: 4, BlockhashSlotDelta: 6})
+	if send, blockhash := slots.Mean(); slots.Count != 4 || send != 3 || blockhash != 5 {
+		t.Fatal("slot mean should be weighted by count", slots)
+	}
+}
+
+func TestSignatureSubscriber(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "isBlockhashValid") { // every blockhash is expired
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"context":{"slot":1},"value":false}}`))
+			return
+		}
+		// getSignatureStatuses: not found yet
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"context":{"slot":1},"value":[null]}}`))
+	})
+	mux.Handle("/ws", websocket.Handler(func(conn *websocket.Conn) {
+		subscription := uint64(10)
+		for {
+			var req wsRequest
+			if err := websocket.JSON.Receive(conn, &req); err != nil {
+				return
+			}
+			if req.Method != "signatureSubscribe" {
+				continue
+			}
+			if req.Params[0] == "lost" { // a tx which never lands
+				websocket.JSON.Send(conn, map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": 1000 + req.ID})
+				continue
+			}
+			subscription++
+			websocket.JSON.Send(conn, map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": subscription})
+			if subscription%2 == 0 { // notify after both subscriptions of a tx
+				for _, s := range []uint64{subscription - 1, subscription} {
+					websocket.JSON.Send(conn, map[string]interface{}{"jsonrpc": "2.0", "method": "signatureNotification",
+						"params": map[string]interface{}{"subscription": s, "result": map[string]interface{}{"context": map[string]uint64{"slot": 5}, "value": map[string]interface{}{"err": nil}}}})
+				}
+			}
+		}
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	c := client.NewClient(server.URL)
+	conf := ClusterConfig{}
+	conf.PingConfig.ConfirmationMode = string(WebsocketConfirmation)
+	conf.CLIConfig.WebsocketURL = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	ws, err := NewSignatureSubscriber(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ { // the connection is reused
+		stages := StageTimer{}
+		stages.Start()
+		stages.Sent()
+		if waitErr := waitConfirmationBySubscriber(ws, c, "sig", 5*time.Second, time.Second, time.Second, &stages); waitErr.HasError() {
+			t.Fatal(waitErr)
+		}
+		if stages.Times[StageProcessed] == 0 || stages.Times[StageConfirmed] == 0 {
+			t.Fatal("processed and confirmed should be notified", stages.Times)
+		}
+	}
+	start := time.Now()
+	stages := StageTimer{}
+	stages.Start()
+	stages.Sent()
+	waitErr := waitConfirmationOrBlockhashInvalidBySubscriber(ws, c, "lost", "blockhash", 30*time.Second, &stages)
+	if !strings.Contains(string(waitErr), "blockhash is not valid") || time.Since(start) > 10*time.Second {
+		t.Fatal("expired blockhash should be found while waiting on the socket", waitErr, time.Since(start))
+	}
+	fixedURL := ws.url
+	ws.Follow("http://127.0.0.1:8899")
+	follower := &SignatureSubscriber{url: "ws://127.0.0.1:8900"}
+	follower.Follow("https://api.devnet.solana.com")
+	if ws.url != fixedURL || follower.url != "wss://api.devnet.solana.com" {
+		t.Fatal("subscriber should follow the rpc endpoint unless websocket_url is configured", ws.url, follower.url)
+	}
+	if websocketURLFromRPC("http://localhost:8899") != "ws://localhost:8900" || websocketURLFromRPC("https://api.devnet.solana.com") != "wss://api.devnet.solana.com" {
+		t.Fatal("websocket url from rpc url is not correct")
+	}
+	conf.PingConfig.ConfirmationMode = "push"
+	if _, err := NewSignatureSubscriber(conf); !errors.Is(err, ErrInvalidConfirmationMode) {
+		t.Fatal("unknown mode should fail", err)
+	}
+}
+
+func TestFeeStrategy(t *testing.T) {
+	fees := []uint64{50, 10, 40, 20, 30}
+	if p := recentFeePrice(MaxFee, fees, 100); p.Price != 50 || p.Inputs != "samples=5,value=50" {
+		t.Fatal("max fee is not correct", p)
+	}
+	if p := recentFeePrice(PercentileFee, fees, 75); p.Price != 40 {
+		t.Fatal("75 percentile fee is not correct", p)
+	}
+	if p := recentFeePrice(MedianMultiplierFee, fees, 50); p.Price != 30 {
+		t.Fatal("median fee is not correct", p)
+	}
+	if p := recentFeePrice(MaxFee, nil, 100); p.Price != 0 || p.Inputs != "samples=0" {
+		t.Fatal("no fee should be 0", p)
+	}
+	conf := PingConfig{ComputeUnitPrice: 200}
+	conf.FeeStrategy = FeeStrategyConfig{Type: string(FixedFee), Cap: 100}
+	s, err := NewFeeStrategy(conf, InfluxdbConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := s.Price(nil, types.Account{}); p.Price != 100 || p.Strategy != FixedFee || p.Inputs != "value=200,capped=100" {
+		t.Fatal("fixed fee should be capped", p)
+	}
+	for _, invalid := range []FeeStrategyConfig{{Type: "auction"}, {Type: string(PercentileFee)}, {Type: string(MedianMultiplierFee)}, {Type: string(InfluxFee)}} {
+		conf.FeeStrategy = invalid
+		if _, err := NewFeeStrategy(conf, InfluxdbConfig{}); !errors.Is(err, ErrInvalidFeeStrategy) {
+			t.Fatal("invalid strategy should fail", invalid, err)
+		}
+	}
+	memo := feeStrategyMemo([]PingResult{{FeeStrategy: "max", Submitted: 10, Confirmed: 9}, {FeeStrategy: "fixed", Submitted: 10, Confirmed: 10}})
+	if memo != "fixed 10tx loss 0.0%, max 10tx loss 10.0%" {
+		t.Fatal("strategy memo is not correct", memo)
+	}
+}
+
+func TestFeeLadder(t *testing.T) {
+	conf := PingConfig{FeeLadder: []uint64{0, 1000, 10000}}
+	ladder, err := NewFeeLadder(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ladder) != 3 || !conf.ComputeFeeEnabled() {
+		t.Fatal("each tier should have a strategy", ladder)
+	}
+	if p := ladder[2].Price(nil, types.Account{}); p.Price != 10000 || p.Strategy != LadderFee || p.Inputs != "tier=2,value=10000" {
+		t.Fatal("ladder price is not correct", p)
+	}
+	for _, invalid := range []PingConfig{{FeeLadder: []uint64{0, 0}}, {FeeLadder: []uint64{DefaultFeeCap + 1}}, {FeeLadder: []uint64{0, 1000}, ComputeFeeDualMode: true}} {
+		if _, err := NewFeeLadder(invalid); !errors.Is(err, ErrInvalidFeeStrategy) {
+			t.Fatal("invalid ladder should fail", invalid, err)
+		}
+	}
+	if ladder, err := NewFeeLadder(PingConfig{ComputeFeeDualMode: true}); err != nil || ladder != nil {
+		t.Fatal("dual mode without ladder should be allowed", ladder, err)
+	}
+	data := &GroupsAllStatistic{FeeTiers: []FeeTierStatistic{
+		{ComputeUnitPrice: 0, GlobalStatistic: GlobalStatistic{Submitted: 10, Confirmed: 5, Loss: 0.5, Count: 1}},
+		{ComputeUnitPrice: 1000},
+	}}
+	section := feeTierRecordBlock(data)
+	if !strings.Contains(section, "( 0ml,  10,   5, 50.0%") || !strings.Contains(section, "( 1000ml, no data )") {
+		t.Fatal("fee tier section is not correct", section)
+	}
+	if feeTierRecordBlock(&GroupsAllStatistic{}) != "" {
+		t.Fatal("no section without tiers")
+	}
+}
+
+func TestWorkerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		goWorker(func() { sleepContext(ctx, time.Hour) })
+	}()
+	if waitWorkers(100 * time.Millisecond) {
+		t.Fatal("worker should be running")
+	}
+	cancel()
+	if !waitWorkers(time.Second) {
+		t.Fatal("worker should exit after cancel")
+	}
+	i := NewInfluxdbClient(InfluxdbConfig{InfluxdbURL: "http://127.0.0.1:1"})
+	defer i.ClientClose()
+	if !i.Flush(time.Second) {
+		t.Fatal("flush without writes should not timeout")
+	}
+	i.SendDatapointAsync(i.PrepareInfluxdbData(PingResult{Cluster: "test"}))
+	if !i.Flush(10 * time.Millisecond) {
+		t.Fatal("writes after flush should be dropped")
+	}
+}
+
+func TestAPIAuthClientIP(t *testing.T) {
+	auth := newAPIAuth(APIAuth{Enabled: true, PublicAccess: true, PublicRateLimit: 1, PublicBurst: 1, TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"}})
+	r := httptest.NewRequest(http.MethodGet, "/mainnet-beta/latest", nil)
+	r.RemoteAddr = "1.2.3.4:5000"
+	r.Header.Set("X-Forwarded-For", "9.9.9.9")
+	if ip := auth.clientIP(r); ip != "1.2.3.4" {
+		t.Fatal("X-Forwarded-For of an untrusted peer should be ignored", ip)
+	}
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "9.9.9.9, 5.6.7.8, 192.168.1.1")
+	if ip := auth.clientIP(r); ip != "5.6.7.8" {
+		t.Fatal("client ip should be the right-most untrusted address", ip)
+	}
+	for i := 0; i < maxPublicClients+10; i++ {
+		auth.allow("", fmt.Sprintf("ip-%d", i))
+	}
+	if len(auth.public) != maxPublicClients+1 {
+		t.Fatal("public limiters should be capped", len(auth.public))
+	}
+	if _, status := auth.allow("", "new-client"); status != http.StatusTooManyRequests {
+		t.Fatal("new clients should share the overflow limiter", status)
+	}
+}
+
+func TestAPIAuthClusters(t *testing.T) {
+	saved := config.Clusters
+	defer func() { config.Clusters = saved }()
+	config.Clusters = []ClusterConfig{
+		{Cluster: MainnetBeta, Route: "mainnet-beta", ClusterPing: ClusterPing{APIServer: APIServer{Auth: APIAuth{Enabled: true,
+			Keys: []APIKey{{Key: "main-key"}, {Key: "main-admin", Admin: true}}}}}},
+		{Cluster: Testnet, Route: "testnet"},
+		{Cluster: Devnet, Route: "devnet", ClusterPing: ClusterPing{APIServer: APIServer{Auth: APIAuth{Enabled: true, Keys: []APIKey{{Key: "dev-key"}}}}}},
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router := gin.New()
+	// the server of testnet, whose auth is not enabled
+	router.Use(apiAuthMiddleware(Testnet, newClusterAuths(), []Cluster{MainnetBeta, Testnet, Devnet}))
+	router.GET("/:cluster/latest", ok)
+	router.POST("/:cluster/failover/switch", adminAuthMiddleware(), ok)
+	router.GET("/compare", ok)
+	router.GET("/status", ok)
+	for _, tc := range []struct {
+		method string
+		path   string
+		key    string
+		status int
+	}{
+		{http.MethodGet, "/testnet/latest", "", http.StatusOK},
+		{http.MethodGet, "/devnet/latest", "", http.StatusUnauthorized},
+		{http.MethodGet, "/devnet/latest", "main-key", http.StatusUnauthorized},
+		{http.MethodGet, "/devnet/latest", "dev-key", http.StatusOK},
+		{http.MethodGet, "/mainnet-beta/latest", "main-key", http.StatusOK},
+		{http.MethodGet, "/compare?clusters=testnet,mainnet-beta", "", http.StatusUnauthorized},
+		{http.MethodGet, "/compare?clusters=testnet,mainnet-beta", "main-key", http.StatusOK},
+		{http.MethodGet, "/compare?clusters=devnet,mainnet-beta", "main-key", http.StatusUnauthorized},
+		{http.MethodGet, "/status", "", http.StatusUnauthorized},
+		{http.MethodPost, "/devnet/failover/switch", "main-admin", http.StatusUnauthorized},
+		{http.MethodPost, "/devnet/failover/switch", "dev-key", http.StatusForbidden},
+		{http.MethodPost, "/testnet/failover/switch", "main-admin", http.StatusForbidden},
+		{http.MethodPost, "/mainnet-beta/failover/switch", "main-key", http.StatusForbidden},
+		{http.MethodPost, "/mainnet-beta/failover/switch", "main-admin", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if len(tc.key) > 0 {
+			r.Header.Set(APIKeyHeader, tc.key)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Fatal(tc.method, tc.path, tc.key, "should be", tc.status, "but", w.Code)
+		}
+	}
+}
+
+func TestErrorBreakdown(t *testing.T) {
+	ResponseErrIdentifierInit()
+	records := []PingResult{
+		{TimeStamp: 50, Error: pq.StringArray{ErrInProcessedStateTimeout.Error(), ConfirmationExceedsText}},
+		{TimeStamp: 100, Error: pq.StringArray{BlockhashNotValidText, "unknown error"}},
+		{TimeStamp: 120, Error: pq.StringArray{TooManyRequest429Text, ErrWaitForConfirmedTimeout.Error()}},
+	}
+	ret, err := errorBreakdownOf(0, 120, 60, eachResult(records))
+	if err != nil || len(ret.Data) != 2 || ret.Data[0].TimeStampUnix != 120 || ret.Data[1].TimeStampUnix != 60 {
+		t.Fatal("groups are not correct", ret.Data)
+	}
+	latest, earlier := ret.Data[0].Errors, ret.Data[1].Errors
+	if ret.Data[0].Total != 4 || latest["429-too-many-requests"] != 1 || latest["wait-confirmed-timeout"] != 1 ||
+		latest["blockhash-expired"] != 1 || latest[OtherErrorCategory] != 1 {
+		t.Fatal("errors of the latest group are not correct", ret.Data[0])
+	}
+	if ret.Data[1].Total != 2 || earlier["processed-state-timeout"] != 1 || earlier["confirmation-exceeds-3mins"] != 1 || earlier["no-such-host"] != 0 {
+		t.Fatal("errors of the earlier group are not correct", ret.Data[1])
+	}
+	if ret, _ := errorBreakdownOf(0, 120, 60, eachResult(nil)); len(ret.Data) != 0 {
+		t.Fatal("no data should have no group", ret.Data)
+	}
+}
@@ -18,9 +18,11 @@ func SlackSend(webhookURL string, payload *SlackPayload) []error {
 	resp, _, errs := request.Post(webhookURL).Send(string(data)).End()
 
 	if errs != nil {
+		metricWebhookFailures.WithLabelValues("slack").Inc()
 		return errs
 	}
 	if resp.StatusCode >= 400 {
+		metricWebhookFailures.WithLabelValues("slack").Inc()
 		log.Println(resp.StatusCode, " payload:", string(data))
 		return []error{fmt.Errorf("slack sending msg. Status: %v", resp.Status)}
 	}
@@ -39,9 +41,11 @@ func DiscordSend(webhookURL string, payload *DiscordPayload) []error {
 	resp, _, errs := request.Post(webhookURL).Send(string(data)).End()
 
 	if errs != nil {
+		metricWebhookFailures.WithLabelValues("discord").Inc()
 		return errs
 	}
 	if resp.StatusCode >= 400 {
+		metricWebhookFailures.WithLabelValues("discord").Inc()
 		log.Println(resp.StatusCode, " payload:", string(data))
 		return []error{fmt.Errorf("Discord sending msg. Status: %v", resp.Status)}
 	}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/blocto/solana-go-sdk/client"
 )
@@ -14,11 +15,13 @@ var failoverMutex sync.Mutex
 
 type FailoverEndpointList []FailoverEndpoint
 type FailoverEndpoint struct {
-	Endpoint    string
-	AccessToken string
-	Piority     int
-	MaxRetry    int
-	Retry       int
+	Endpoint      string
+	AccessToken   string
+	Piority       int
+	MaxRetry      int
+	Retry         int
+	LastError     string // category of the last error
+	LastErrorTime int64
 }
 
 type RPCFailover struct {
@@ -65,35 +68,83 @@ func (f *RPCFailover) GetNext() string {
 	return f.Endpoints[f.curIndex].Endpoint
 }
 
-func (f *RPCFailover) GoNext(cur *client.Client, config ClusterConfig, workerNum int) *client.Client {
+// GoNext return a client of the current endpoint. It switches to the next endpoint if the current one fails.
+// clientIndex is the index of the endpoint which cur connects to. A new client is created when the current endpoint
+// is not clientIndex, e.g. another worker or an admin switched the endpoint.
+func (f *RPCFailover) GoNext(cur *client.Client, clientIndex *int, config ClusterConfig, workerNum int) *client.Client {
+	var slack *SlackPayload // sent after unlocking so that a slow webhook does not block the other users of failoverMutex
+	defer func() {
+		if slack != nil {
+			SlackSend(config.AlternativeEnpoint.SlackAlert.Webhook, slack)
+		}
+	}()
 	failoverMutex.Lock()
 	defer failoverMutex.Unlock()
-	var next *client.Client
-	retries := f.GetEndpoint().Retry
-	if retries < f.GetEndpoint().MaxRetry { // Go Next
-		if cur != nil {
-			return cur
-		} else {
-			connectionEndpoint := f.GetEndpoint().Endpoint
-			if len(f.GetEndpoint().AccessToken) != 0 {
-				connectionEndpoint = fmt.Sprintf("%s/%s", f.GetEndpoint().Endpoint, f.GetEndpoint().AccessToken)
-			}
-			return client.NewClient(connectionEndpoint)
+	if f.GetEndpoint().Retry >= f.GetEndpoint().MaxRetry {
+		f.GetNextIndex()
+		f.GetEndpoint().Retry = 0
+		log.Println("GoNext!!! New Endpoint:", f.GetEndpoint().Endpoint)
+		metricFailoverSwitches.WithLabelValues(string(config.Cluster), f.GetEndpoint().Endpoint).Inc()
+		if config.AlternativeEnpoint.SlackAlert.Enabled {
+			slack = &SlackPayload{}
+			slack.FailoverAlertPayload(config, *f.GetEndpoint(), workerNum)
 		}
 	}
-	idx := f.GetNextIndex()
-	if len(f.Endpoints[idx].AccessToken) != 0 {
-		next = client.NewClient(fmt.Sprintf("%s/%s", f.Endpoints[idx].Endpoint, f.Endpoints[idx].AccessToken))
-	} else {
-		next = client.NewClient(f.Endpoints[idx].Endpoint)
+	if cur != nil && *clientIndex == f.curIndex {
+		return cur
+	}
+	*clientIndex = f.curIndex
+	return client.NewClient(f.GetEndpoint().RPCURL())
+}
+
+// RPCURL return the url of the endpoint with its access token
+func (e *FailoverEndpoint) RPCURL() string {
+	if len(e.AccessToken) != 0 {
+		return fmt.Sprintf("%s/%s", e.Endpoint, e.AccessToken)
 	}
-	log.Println("GoNext!!! New Endpoint:", f.GetEndpoint())
-	if config.AlternativeEnpoint.SlackAlert.Enabled {
-		var slack SlackPayload
-		slack.FailoverAlertPayload(config, *f.GetEndpoint(), workerNum)
-		SlackSend(config.AlternativeEnpoint.SlackAlert.Webhook, &slack)
+	return e.Endpoint
+}
+
+// SwitchTo make the endpoint of index the current endpoint and reset its retry count.
+// It returns a copy of the endpoint taken with the lock held
+func (f *RPCFailover) SwitchTo(index int) (FailoverEndpoint, error) {
+	failoverMutex.Lock()
+	defer failoverMutex.Unlock()
+	if index < 0 || index >= len(f.Endpoints) {
+		return FailoverEndpoint{}, ErrInvalidEndpointIndex
+	}
+	f.curIndex = index
+	f.Endpoints[index].Retry = 0
+	log.Println("Switch to Endpoint:", f.Endpoints[index].Endpoint)
+	return f.Endpoints[index], nil
+}
+
+// ResetRetry reset retry counts of all endpoints
+func (f *RPCFailover) ResetRetry() {
+	failoverMutex.Lock()
+	defer failoverMutex.Unlock()
+	for i := range f.Endpoints {
+		f.Endpoints[i].Retry = 0
 	}
-	return next
+}
+
+// Snapshot return the index of the current endpoint and a copy of endpoints
+func (f *RPCFailover) Snapshot() (int, []FailoverEndpoint) {
+	failoverMutex.Lock()
+	defer failoverMutex.Unlock()
+	endpoints := make([]FailoverEndpoint, len(f.Endpoints))
+	copy(endpoints, f.Endpoints)
+	return f.curIndex, endpoints
+}
+
+// EndpointAt return the endpoint of index
+func (f *RPCFailover) EndpointAt(index int) *FailoverEndpoint {
+	return &f.Endpoints[index]
+}
+
+// GetClusterFailover return the RPCFailover of the cluster
+func GetClusterFailover(c Cluster) *RPCFailover {
+	return clusterFailovers[c]
 }
 
 func (f *RPCFailover) GetEndpoint() *FailoverEndpoint {
@@ -104,12 +155,15 @@ func (f *FailoverEndpoint) RetryResult(err PingResultError) {
 	failoverMutex.Lock()
 	defer failoverMutex.Unlock()
 	if err.HasError() {
+		f.LastError = err.Category()
+		f.LastErrorTime = time.Now().UTC().Unix()
 		if err.IsTooManyRequest429() ||
 			err.IsServiceUnavilable() ||
 			err.IsErrGatewayTimeout504() ||
 			err.IsNoSuchHost() ||
 			err.IsConnectionRefused() {
 			f.Retry += 1
+			metricFailoverRetries.WithLabelValues(f.Endpoint).Inc()
 		}
 	} else {
 		f.Retry = 0
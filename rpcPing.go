@@ -1,14 +1,11 @@
 package main
 
 import (
-	"context"
 	"math"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/blocto/solana-go-sdk/client"
-	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/types"
 )
 
@@ -21,29 +18,45 @@ type TakeTime struct {
 	End   int64
 }
 
-// Ping similar to solana-bench-tps. It send a transaction to the cluster
-func Ping(c *client.Client, pType PingType, acct types.Account, config ClusterConfig, feeEnabled bool) (PingResult, PingResultError) {
+// Ping similar to solana-bench-tps. It send transactions built by tx to the cluster with the price of fee.
+// Confirmations are waited by ws if it is not nil, otherwise by polling
+func Ping(c *client.Client, pType PingType, acct types.Account, config ClusterConfig, tx PingTx, ws *SignatureSubscriber, fee *FeeStrategy, feeEnabled bool) (PingResult, PingResultError) {
 	resultErrs := []string{}
 	timer := TakeTime{}
 	result := PingResult{
 		Cluster:  string(config.Cluster),
 		Hostname: config.HostName,
 		PingType: string(pType),
+		TxType:   string(tx.Type()),
 	}
 	confirmedCount := 0
+	stageMeasure := StageMeasure{}
+	slotMeasure := SlotMeasure{}
+	txTimes, txStages := []int64{}, []int64{} // stored so that groups compute percentiles of txs
+	addStages := func(s PingStages) {
+		stageMeasure.Add(s)
+		txStages = append(txStages, s[:]...)
+	}
 
-	computeUnitPrice := getFee(c, acct)
+	withFee := feeEnabled && config.ComputeFeeEnabled()
+	var feePrice FeePrice // only looked up when the fee is attached, strategies may query rpc or influxdb
+	if withFee {
+		feePrice = fee.Price(c, acct)
+	}
+	computeUnitPrice := feePrice.Price
 
 	for i := 0; i < config.BatchCount; i++ {
 		if i > 0 {
 			time.Sleep(time.Duration(config.BatchInverval))
 		}
 		timer.TimerStart()
+		stages := StageTimer{}
 
-		if !feeEnabled || 0 == config.ComputeUnitPrice {
-			txhash, pingErr := Transfer(c, acct, acct, config.Receiver, time.Duration(config.TxTimeout)*time.Second)
+		if tx.Type() == TransferTx && !withFee {
+			txhash, pingErr := Transfer(c, acct, acct, config.Receiver, time.Duration(config.TxTimeout)*time.Second, &stages)
 			if pingErr.HasError() {
 				timer.TimerStop()
+				addStages(stages.Times)
 				if !pingErr.IsInErrorList(PingTakeTimeErrExpectionList) {
 					timer.Add()
 				}
@@ -51,15 +64,18 @@ func Ping(c *client.Client, pType PingType, acct types.Account, config ClusterCo
 				continue
 			}
 
-			waitErr := waitConfirmation(
+			waitErr := waitConfirmationBySubscriber(
+				ws,
 				c,
 				txhash,
 				time.Duration(config.WaitConfirmationTimeout)*time.Second,
 				time.Duration(WaitConfirmationQueryTimeout)*time.Second,
 				time.Duration(config.StatusCheckInterval)*time.Millisecond,
+				&stages,
 			)
 			timer.TimerStop()
 			if waitErr.HasError() {
+				addStages(stages.Times)
 				resultErrs = append(resultErrs, string(waitErr))
 				if !waitErr.IsInErrorList(PingTakeTimeErrExpectionList) {
 					timer.Add()
@@ -68,25 +84,33 @@ func Ping(c *client.Client, pType PingType, acct types.Account, config ClusterCo
 			}
 			timer.Add()
 			confirmedCount++
+			txTimes = append(txTimes, timer.Times[len(timer.Times)-1])
+			stages.LandedSlot = getLandedSlot(c, txhash)
+			slotMeasure.AddTx(&stages)
+			if config.TrackFinalized {
+				waitFinalized(c, txhash, time.Duration(config.WaitConfirmationTimeout)*time.Second, time.Duration(config.StatusCheckInterval)*time.Millisecond, &stages)
+			}
+			addStages(stages.Times)
 		} else {
-			txhash, blockhash, pingErr := SendPingTx(SendPingTxParam{
-				Client:              c,
-				FeePayer:            acct,
-				RequestComputeUnits: config.RequestUnits,
-				ComputeUnitPrice:    computeUnitPrice,
-				ReceiverPubkey:      config.Receiver,
-			})
+			param := SendPingTxParam{Client: c, FeePayer: acct, Tx: tx, Stages: &stages}
+			if withFee {
+				param.RequestComputeUnits = config.RequestUnits
+				param.ComputeUnitPrice = computeUnitPrice
+			}
+			txhash, blockhash, pingErr := SendPingTx(param)
 			if pingErr.HasError() {
 				timer.TimerStop()
+				addStages(stages.Times)
 				if !pingErr.IsInErrorList(PingTakeTimeErrExpectionList) {
 					timer.Add()
 				}
 				resultErrs = append(resultErrs, string(pingErr))
 				continue
 			}
-			waitErr := waitConfirmationOrBlockhashInvalid(c, txhash, blockhash)
+			waitErr := waitConfirmationOrBlockhashInvalidBySubscriber(ws, c, txhash, blockhash, time.Duration(config.WaitConfirmationTimeout)*time.Second, &stages)
 			timer.TimerStop()
 			if waitErr.HasError() {
+				addStages(stages.Times)
 				resultErrs = append(resultErrs, string(waitErr))
 				if !waitErr.IsInErrorList(PingTakeTimeErrExpectionList) {
 					timer.Add()
@@ -95,6 +119,13 @@ func Ping(c *client.Client, pType PingType, acct types.Account, config ClusterCo
 			}
 			timer.Add()
 			confirmedCount++
+			txTimes = append(txTimes, timer.Times[len(timer.Times)-1])
+			stages.LandedSlot = getLandedSlot(c, txhash)
+			slotMeasure.AddTx(&stages)
+			if config.TrackFinalized {
+				waitFinalized(c, txhash, time.Duration(config.WaitConfirmationTimeout)*time.Second, time.Duration(config.StatusCheckInterval)*time.Millisecond, &stages)
+			}
+			addStages(stages.Times)
 		}
 	}
 	result.TimeStamp = time.Now().UTC().Unix()
@@ -107,7 +138,15 @@ func Ping(c *client.Client, pType PingType, acct types.Account, config ClusterCo
 	result.Min = min
 	result.Stddev = int64(stdDev)
 	result.TakeTime = total
+	result.P50, result.P90, result.P99 = timer.Percentiles()
+	result.TakeTimes = timer.Times
+	result.TxTimes, result.TxStages = txTimes, txStages
+	result.SetStages(stageMeasure.Mean())
+	result.SlotCount = slotMeasure.Count
+	result.SendSlotDelta, result.BlockhashSlotDelta = slotMeasure.Mean()
 	result.ComputeUnitPrice = computeUnitPrice
+	result.FeeStrategy = string(feePrice.Strategy)
+	result.FeeInputs = feePrice.Inputs
 	result.RequestComputeUnits = config.RequestUnits
 	result.Error = resultErrs
 	stringErrors := []string(result.Error)
@@ -181,29 +220,30 @@ func (t *TakeTime) Statistic() (max int64, mean float64, min int64, stddev float
 	return
 }
 
-func getFee(c *client.Client, account types.Account) uint64 {
-	// at least 1 to trigger the system send the fee tx. can be updated if the logic is fixed.
-	computeUnitPrice := uint64(1)
-
-	// get the max(the last 100 blocks)
-	fees, err := c.GetRecentPrioritizationFees(context.Background(), []common.PublicKey{account.PublicKey})
-	if err == nil {
-		sort.Slice(fees, func(i, j int) bool {
-			return fees[i].Slot > fees[j].Slot
-		})
-		for i := 0; i < len(fees) && i < 100; i++ {
-			var pFee = fees[i].PrioritizationFee
-			if pFee > computeUnitPrice {
-				computeUnitPrice = pFee
-			}
-
-			cap := uint64(100_000_000)
-			if computeUnitPrice > cap {
-				computeUnitPrice = cap
-				break
-			}
+// Percentiles analyze data in TakeTime by a LatencyHistogram to return p50/p90/p99
+func (t *TakeTime) Percentiles() (p50 int64, p90 int64, p99 int64) {
+	h := NewLatencyHistogram()
+	for _, ts := range t.Times {
+		if ts <= 0 { // do not use 0 data because it is the bad data
+			continue
 		}
+		h.Add(ts)
 	}
+	return h.Percentile(50), h.Percentile(90), h.Percentile(99)
+}
 
-	return computeUnitPrice
+// TimeStatistic return all statistic of TakeTime
+func (t *TakeTime) TimeStatistic() TimeStatistic {
+	max, mean, min, stddev, sum := t.Statistic()
+	p50, p90, p99 := t.Percentiles()
+	return TimeStatistic{
+		Min:    min,
+		Mean:   mean,
+		Max:    max,
+		Stddev: stddev,
+		Sum:    sum,
+		P50:    p50,
+		P90:    p90,
+		P99:    p99,
+	}
 }
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"golang.org/x/net/websocket"
+)
+
+// ConfirmationMode is how Ping waits for the confirmation of a tx
+type ConfirmationMode string
+
+const (
+	PollingConfirmation   ConfirmationMode = "polling"   // GetSignatureStatus every StatusCheckInterval
+	WebsocketConfirmation ConfirmationMode = "websocket" // signatureSubscribe. fall back to polling if the socket fails
+)
+
+const (
+	wsDialTimeout = 5 * time.Second
+	// wsRetryInterval is the time to wait before dialing again after the socket fails. Txs are polled meanwhile
+	wsRetryInterval = time.Minute
+	// wsBlockhashCheckInterval is the interval to check whether the blockhash of the tx is still valid while waiting
+	wsBlockhashCheckInterval = 2 * time.Second
+)
+
+// SignatureSubscriber wait for txs by signatureSubscribe on a websocket connection.
+// It is used by one worker only, so a connection handles one tx at a time.
+// Without websocket_url in the solana cli config, it connects to the active rpc endpoint of the failover (see Follow),
+// so confirmations are measured on the node which the tx is sent to. An explicit websocket_url is always used.
+type SignatureSubscriber struct {
+	url      string
+	fixed    bool // url is websocket_url of the solana cli config
+	conn     *websocket.Conn
+	messages chan wsReceive // messages of conn read by readLoop
+	done     chan struct{}  // closed when conn is closed
+	nextID   int
+	retryAt  time.Time
+}
+
+type wsReceive struct {
+	msg wsMessage
+	err error
+}
+
+type wsRequest struct {
+	JsonRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// wsMessage is a response of a request or a notification of a subscription
+type wsMessage struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Method string `json:"method"`
+	Params struct {
+		Subscription uint64 `json:"subscription"`
+	} `json:"params"`
+}
+
+// NewSignatureSubscriber create a SignatureSubscriber if ConfirmationMode is websocket. It returns nil for polling
+func NewSignatureSubscriber(conf ClusterConfig) (*SignatureSubscriber, error) {
+	switch ConfirmationMode(conf.PingConfig.ConfirmationMode) {
+	case "", PollingConfirmation:
+		return nil, nil
+	case WebsocketConfirmation:
+		if len(conf.CLIConfig.WebsocketURL) > 0 {
+			return &SignatureSubscriber{url: conf.CLIConfig.WebsocketURL, fixed: true}, nil
+		}
+		wsURL := websocketURLFromRPC(conf.CLIConfig.JsonRPCURL)
+		if len(wsURL) == 0 {
+			return nil, fmt.Errorf("%w: no websocket_url or json_rpc_url in solana cli config", ErrInvalidConfirmationMode)
+		}
+		return &SignatureSubscriber{url: wsURL}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidConfirmationMode, conf.PingConfig.ConfirmationMode)
+}
+
+// Follow connect to the websocket of rpcURL from the next tx, unless websocket_url is configured
+func (s *SignatureSubscriber) Follow(rpcURL string) {
+	wsURL := websocketURLFromRPC(rpcURL)
+	if s.fixed || len(wsURL) == 0 || wsURL == s.url {
+		return
+	}
+	s.close()
+	s.url = wsURL
+	s.retryAt = time.Time{}
+}
+
+// websocketURLFromRPC compute the websocket url from the rpc url in the same way as solana cli:
+// http becomes ws, https becomes wss and an explicit port is increased by 1
+func websocketURLFromRPC(rpcURL string) string {
+	u, err := url.Parse(rpcURL)
+	if err != nil || len(u.Host) == 0 {
+		return ""
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return ""
+	}
+	if port := u.Port(); len(port) > 0 {
+		if p, err := strconv.Atoi(port); err == nil {
+			u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(p+1))
+		}
+	}
+	return u.String()
+}
+
+func (s *SignatureSubscriber) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+	if time.Now().Before(s.retryAt) {
+		return ErrWebsocketUnavailable
+	}
+	config, err := websocket.NewConfig(s.url, "http://localhost")
+	if err != nil {
+		return s.fail(err)
+	}
+	config.Dialer = &net.Dialer{Timeout: wsDialTimeout}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return s.fail(err)
+	}
+	s.conn = conn
+	s.messages = make(chan wsReceive)
+	s.done = make(chan struct{})
+	go readLoop(conn, s.messages, s.done)
+	return nil
+}
+
+// readLoop deliver messages of conn until it fails or done is closed
+func readLoop(conn *websocket.Conn, messages chan<- wsReceive, done <-chan struct{}) {
+	for {
+		var r wsReceive
+		r.err = websocket.JSON.Receive(conn, &r.msg)
+		select {
+		case messages <- r:
+		case <-done:
+			return
+		}
+		if r.err != nil {
+			return
+		}
+	}
+}
+
+// fail close the connection and wait wsRetryInterval before dialing again
+func (s *SignatureSubscriber) fail(err error) error {
+	log.Println("signatureSubscribe ", s.url, " error:", err, ". poll signature status for ", wsRetryInterval)
+	s.close()
+	s.retryAt = time.Now().Add(wsRetryInterval)
+	return err
+}
+
+func (s *SignatureSubscriber) close() {
+	if s.conn != nil {
+		close(s.done)
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *SignatureSubscriber) send(method string, params ...interface{}) (int, error) {
+	s.nextID++
+	return s.nextID, websocket.JSON.Send(s.conn, wsRequest{JsonRPC: "2.0", ID: s.nextID, Method: method, Params: params})
+}
+
+// WaitConfirmed wait until the tx is confirmed, timeout or expired returns true. Processed and confirmed stages are
+// recorded when they are notified. expired is checked every wsBlockhashCheckInterval if it is not nil.
+// err is not nil if the socket fails, then the caller should poll the status instead.
+func (s *SignatureSubscriber) WaitConfirmed(c *client.Client, txHash string, timeout time.Duration, stages *StageTimer, expired func() bool) (confirmed bool, err error) {
+	if err := s.connect(); err != nil {
+		return false, err
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(timeout))
+	requests := map[int]rpc.Commitment{}
+	for _, commitment := range []rpc.Commitment{rpc.CommitmentProcessed, rpc.CommitmentConfirmed} {
+		id, err := s.send("signatureSubscribe", txHash, map[string]string{"commitment": string(commitment)})
+		if err != nil {
+			return false, s.fail(err)
+		}
+		requests[id] = commitment
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	check := time.NewTicker(wsBlockhashCheckInterval)
+	defer check.Stop()
+	subscriptions := map[uint64]rpc.Commitment{}
+	for {
+		var msg wsMessage
+		select {
+		case <-timer.C:
+			s.close() // subscriptions which are not notified or not responded yet are dropped with the connection
+			return false, nil
+		case <-check.C:
+			if expired != nil && expired() {
+				s.close()
+				return false, nil
+			}
+			continue
+		case r := <-s.messages:
+			if r.err != nil {
+				return false, s.fail(r.err)
+			}
+			msg = r.msg
+		}
+		if msg.Method == "signatureNotification" {
+			commitment, ok := subscriptions[msg.Params.Subscription]
+			if !ok { // a subscription of a previous tx
+				continue
+			}
+			stages.Seen(commitment)
+			delete(subscriptions, msg.Params.Subscription) // signature subscriptions end after the notification
+			if commitment == rpc.CommitmentConfirmed {
+				s.unsubscribe(subscriptions)
+				return true, nil
+			}
+			continue
+		}
+		commitment, ok := requests[msg.ID]
+		if !ok { // a response of unsubscribe
+			continue
+		}
+		if msg.Error != nil {
+			return false, s.fail(errors.New(msg.Error.Message))
+		}
+		var subscription uint64
+		if err := json.Unmarshal(msg.Result, &subscription); err != nil {
+			return false, s.fail(err)
+		}
+		subscriptions[subscription] = commitment
+		delete(requests, msg.ID)
+		if len(requests) > 0 {
+			continue
+		}
+		// the tx may be confirmed before subscribing
+		status, err := c.GetSignatureStatus(context.Background(), txHash)
+		if err == nil && status != nil && status.ConfirmationStatus != nil {
+			stages.Seen(*status.ConfirmationStatus)
+			if *status.ConfirmationStatus == rpc.CommitmentConfirmed || *status.ConfirmationStatus == rpc.CommitmentFinalized {
+				s.unsubscribe(subscriptions)
+				return true, nil
+			}
+		}
+	}
+}
+
+// unsubscribe cancel subscriptions which are not notified. Responses are skipped by the next WaitConfirmed
+func (s *SignatureSubscriber) unsubscribe(subscriptions map[uint64]rpc.Commitment) {
+	for subscription := range subscriptions {
+		if _, err := s.send("signatureUnsubscribe", subscription); err != nil {
+			s.fail(err)
+			return
+		}
+	}
+}
+
+// waitConfirmationBySubscriber is waitConfirmation by signatureSubscribe. It polls if ws is nil or the socket fails
+func waitConfirmationBySubscriber(ws *SignatureSubscriber, c *client.Client, txHash string, timeout time.Duration, requestTimeout time.Duration, checkInterval time.Duration, stages *StageTimer) PingResultError {
+	if ws != nil {
+		if timeout <= 0 {
+			timeout = waitConfirmationTimeoutDefault
+		}
+		confirmed, err := ws.WaitConfirmed(c, txHash, timeout, stages, nil)
+		if err == nil {
+			if confirmed {
+				return EmptyPingResultError
+			}
+			if stages.Times[StageProcessed] > 0 {
+				return PingResultError(ErrInProcessedStateTimeout.Error())
+			}
+			return PingResultError(ErrWaitForConfirmedTimeout.Error())
+		}
+	}
+	return waitConfirmation(c, txHash, timeout, requestTimeout, checkInterval, stages)
+}
+
+// waitConfirmationOrBlockhashInvalidBySubscriber is waitConfirmationOrBlockhashInvalid by signatureSubscribe.
+// The blockhash is checked while waiting. If the tx is neither confirmed nor expired in timeout, it polls
+// until the blockhash is invalid. The whole wait is bounded by waitBlockhashInvalidTimeout as polling.
+func waitConfirmationOrBlockhashInvalidBySubscriber(ws *SignatureSubscriber, c *client.Client, txHash, blockhash string, timeout time.Duration, stages *StageTimer) PingResultError {
+	endTime := time.Now().Add(waitBlockhashInvalidTimeout)
+	if ws != nil {
+		if timeout <= 0 || timeout > waitBlockhashInvalidTimeout {
+			timeout = waitConfirmationTimeoutDefault
+		}
+		blockhashExpired := false
+		expired := func() bool {
+			valid, err := isBlockhashValid(c, context.Background(), blockhash)
+			blockhashExpired = err == nil && !valid
+			return blockhashExpired
+		}
+		confirmed, err := ws.WaitConfirmed(c, txHash, timeout, stages, expired)
+		if err == nil && confirmed {
+			return EmptyPingResultError
+		}
+		if err == nil && blockhashExpired {
+			return PingResultError(fmt.Sprintf("blockhash is not valid, txHash: %v, blockhash: %v, err: %v", txHash, blockhash, nil))
+		}
+	}
+	return waitConfirmationOrBlockhashInvalidUntil(c, txHash, blockhash, endTime, stages)
+}
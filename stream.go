@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// StreamEventResult is the event name of a single PingResult
+	StreamEventResult = "result"
+	// StreamEventAggregate is the event name of a closed 1 min group
+	StreamEventAggregate = "aggregate"
+	// MaxStreamSubscribers limit the number of concurrent stream clients
+	MaxStreamSubscribers = 200
+	// streamSubscriberBuffer is the channel size of a subscriber. Events are dropped if a subscriber is too slow
+	streamSubscriberBuffer = 32
+	// streamAggregateGrace is the time to wait for late results before closing a 1 min group
+	streamAggregateGrace    = 5 * time.Second
+	streamKeepAliveInterval = 15 * time.Second
+)
+
+// StreamEvent is an event pushed to stream subscribers
+type StreamEvent struct {
+	Event string
+	Data  DataPointV2JSON
+}
+
+// PingStream fan out PingResult from pingDataWorker to stream subscribers and aggregate them by minute
+type PingStream struct {
+	mutex       sync.Mutex
+	subscribers map[Cluster]map[chan StreamEvent]struct{}
+	numSubs     int
+	buckets     map[Cluster]map[int64][]PingResult // cluster -> end of minute -> results
+	lastClosed  map[Cluster]int64
+	aggregating bool
+}
+
+var pingStream = NewPingStream()
+
+func NewPingStream() *PingStream {
+	return &PingStream{
+		subscribers: map[Cluster]map[chan StreamEvent]struct{}{},
+		buckets:     map[Cluster]map[int64][]PingResult{},
+		lastClosed:  map[Cluster]int64{},
+	}
+}
+
+// Subscribe return a channel receiving events of the cluster. Return false if there are too many subscribers
+func (s *PingStream) Subscribe(c Cluster) (chan StreamEvent, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.numSubs >= MaxStreamSubscribers {
+		return nil, false
+	}
+	if s.subscribers[c] == nil {
+		s.subscribers[c] = map[chan StreamEvent]struct{}{}
+	}
+	ch := make(chan StreamEvent, streamSubscriberBuffer)
+	s.subscribers[c][ch] = struct{}{}
+	s.numSubs++
+	return ch, true
+}
+
+// Unsubscribe remove the channel from subscribers
+func (s *PingStream) Unsubscribe(c Cluster, ch chan StreamEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.subscribers[c][ch]; ok {
+		delete(s.subscribers[c], ch)
+		s.numSubs--
+	}
+}
+
+// Publish push a PingResult to subscribers and put it into its 1 min group
+func (s *PingStream) Publish(r PingResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c := Cluster(r.Cluster)
+	if !s.aggregating {
+		s.aggregating = true
+		go s.aggregateWorker()
+	}
+	minuteEnd := (r.TimeStamp + 59) / 60 * 60
+	if minuteEnd > s.lastClosed[c] {
+		if s.buckets[c] == nil {
+			s.buckets[c] = map[int64][]PingResult{}
+		}
+		s.buckets[c][minuteEnd] = append(s.buckets[c][minuteEnd], r)
+	}
+	s.broadcast(c, StreamEvent{Event: StreamEventResult, Data: PingResultToV2Json(&r)})
+}
+
+// broadcast must be called with mutex locked
+func (s *PingStream) broadcast(c Cluster, e StreamEvent) {
+	for ch := range s.subscribers[c] {
+		select {
+		case ch <- e:
+		default: // slow subscriber. drop the event
+		}
+	}
+}
+
+// aggregateWorker close 1 min groups and publish their statistic
+func (s *PingStream) aggregateWorker() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.closeGroups(time.Now().UTC().Add(-streamAggregateGrace).Unix())
+	}
+}
+
+// closeGroups publish the statistic of 1 min groups which end at or before now and remove them
+func (s *PingStream) closeGroups(now int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c, buckets := range s.buckets {
+		for minuteEnd, results := range buckets {
+			if minuteEnd > now {
+				continue
+			}
+			groupsStat := statisticCompute(GetClusterConfig(c), []PingGroup{{Result: results, TimeStamp: minuteEnd, Window: 60}})
+			s.broadcast(c, StreamEvent{Event: StreamEventAggregate, Data: PingStatisticToV2Json(&groupsStat.PingStatisticList[0])})
+			delete(buckets, minuteEnd)
+			if minuteEnd > s.lastClosed[c] {
+				s.lastClosed[c] = minuteEnd
+			}
+		}
+	}
+}
+
+// streamPingResult push results and 1 min aggregates of the cluster by Server-Sent Events
+func streamPingResult(c *gin.Context) {
+	cluster, ok := clusterFromParam(c.Param("cluster"))
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	ch, ok := pingStream.Subscribe(cluster)
+	if !ok {
+		log.Println("stream subscribers exceed ", MaxStreamSubscribers)
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+	defer pingStream.Unsubscribe(cluster, ch)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e := <-ch:
+			c.SSEvent(e.Event, e.Data)
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("keepalive", time.Now().UTC().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blocto/solana-go-sdk/client"
@@ -17,102 +19,164 @@ type PingType string
 
 const DefaultAlertThredHold = 20
 const DualModeNoFeeTriggerName = "no-fee-dualmode"
+const FeeLadderTriggerPrefix = "fee-ladder-"
 const (
 	DataPointReport PingType = "report"
 	DataPoint1Min   PingType = "datapoint1min"
 )
 
-func launchWorkers(c ClustersToRun) {
+// workerGroup tracks launchWorkers and all workers it starts, so shutdown can wait for them to exit
+var workerGroup sync.WaitGroup
+
+// startWorkers run launchWorkers in background. Workers exit when ctx is cancelled
+func startWorkers(ctx context.Context, c ClustersToRun) {
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		launchWorkers(ctx, c)
+	}()
+}
+
+// waitWorkers wait for all workers to exit until timeout. Return false if timeout
+func waitWorkers(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		workerGroup.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// goWorker run f as a worker of workerGroup. It must be called by a running worker, so the group is never empty
+func goWorker(f func()) {
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		f()
+	}()
+}
+
+// sleepContext sleep d or until ctx is cancelled. Return false if ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func launchWorkers(ctx context.Context, c ClustersToRun) {
 	// Run Ping Service
-	runCluster := func(clusterConf ClusterConfig) {
+	runCluster := func(clusterConf ClusterConfig) bool {
 		if !clusterConf.PingServiceEnabled {
 			log.Println("==> go pingDataWorker", clusterConf.Cluster, " PingServiceEnabled ", clusterConf.PingServiceEnabled)
 		} else {
 			for i := 0; i < clusterConf.PingConfig.NumWorkers; i++ {
 				log.Println("==> go pingDataWorker", clusterConf.Cluster, " n:", clusterConf.PingConfig.NumWorkers, "i:", i)
-				go pingDataWorker(clusterConf, i)
-				time.Sleep(2 * time.Second)
+				workerNum := i
+				goWorker(func() { pingDataWorker(ctx, clusterConf, workerNum) })
+				if !sleepContext(ctx, 2*time.Second) {
+					return false
+				}
 			}
 		}
 		if clusterConf.Report.Enabled {
-			go reportWorker(clusterConf)
+			goWorker(func() { reportWorker(ctx, clusterConf) })
+		}
+		if clusterConf.APIServer.Enabled {
+			goWorker(func() { historyCacheWorker(ctx, clusterConf.Cluster) })
 		}
+		return true
 	}
-	// Single Cluster or all Cluster
-	switch c {
-	case RunMainnetBeta:
-		runCluster(config.Mainnet)
-	case RunTestnet:
-		runCluster(config.Testnet)
-	case RunDevnet:
-		runCluster(config.Devnet)
-	case RunAllClusters:
-		runCluster(config.Mainnet)
-		runCluster(config.Testnet)
-		runCluster(config.Devnet)
-	default:
-		panic(ErrInvalidCluster)
+	for _, cluster := range c.Clusters() {
+		if !runCluster(GetClusterConfig(cluster)) {
+			return
+		}
 	}
 	// Run Retension Service
 	if config.Retension.Enabled {
-		time.Sleep(2 * time.Second)
-		go retensionServiceWorker()
+		if !sleepContext(ctx, 2*time.Second) {
+			return
+		}
+		goWorker(func() { retensionServiceWorker(ctx) })
 	}
 }
 
-func pingDataWorker(cConf ClusterConfig, workerNum int) {
+func pingDataWorker(ctx context.Context, cConf ClusterConfig, workerNum int) {
 	log.Println(">> Solana DataPoint1MinWorker for ", cConf.Cluster, " worker:", workerNum, " start!")
 	defer log.Println(">> Solana DataPoint1MinWorker for ", cConf.Cluster, " worker:", workerNum, " end!")
-	var failover RPCFailover
 	var c *client.Client
-	var acct types.Account
-
-	switch cConf.Cluster {
-	case MainnetBeta:
-		failover = mainnetFailover
-		clusterAcct, err := getConfigKeyPair(config.ClusterCLIConfig.ConfigMain)
-		if err != nil {
-			log.Panic("getConfigKeyPair Error")
-		}
-		acct = clusterAcct
-	case Testnet:
-		failover = testnetFailover
-		clusterAcct, err := getConfigKeyPair(config.ClusterCLIConfig.ConfigTestnet)
-		if err != nil {
-			log.Panic("Testnet getConfigKeyPair Error")
-		}
-		acct = clusterAcct
-	case Devnet:
-		failover = devnetFailover
-		clusterAcct, err := getConfigKeyPair(config.ClusterCLIConfig.ConfigDevnet)
-		if err != nil {
-			log.Panic("Devnet getConfigKeyPair Error")
-		}
-		acct = clusterAcct
-	default:
+	clusterFailover := GetClusterFailover(cConf.Cluster)
+	if clusterFailover == nil {
 		panic(ErrInvalidCluster)
 	}
+	failover := clusterFailover // shared by workers of the cluster
+	clientIndex := 0            // index of the endpoint which c connects to
+	acct, err := getConfigKeyPair(cConf.CLIConfig)
+	if err != nil {
+		log.Panic(cConf.Name, " getConfigKeyPair Error")
+	}
+	pingTx, err := NewPingTx(cConf.PingConfig)
+	if err != nil {
+		log.Panic(cConf.Name, " NewPingTx Error:", err)
+	}
+	ws, err := NewSignatureSubscriber(cConf)
+	if err != nil {
+		log.Panic(cConf.Name, " NewSignatureSubscriber Error:", err)
+	}
+	fee, err := NewFeeStrategy(cConf.PingConfig, config.InfluxdbConfig)
+	if err != nil {
+		log.Panic(cConf.Name, " NewFeeStrategy Error:", err)
+	}
+	ladder, err := NewFeeLadder(cConf.PingConfig)
+	if err != nil {
+		log.Panic(cConf.Name, " NewFeeLadder Error:", err)
+	}
 	pingWithFee := true
+	ladderIndex := workerNum // workers start at different tiers so that all tiers are sampled at the same time
 
-	for {
-		c = failover.GoNext(c, cConf, workerNum)
-		result, err := Ping(c, DataPoint1Min, acct, cConf, pingWithFee)
-		extraTimeStart := time.Now().UTC().Unix()
-		if cConf.PingConfig.ComputeFeeDualMode {
+	for ctx.Err() == nil {
+		c = failover.GoNext(c, &clientIndex, cConf, workerNum)
+		if ws != nil {
+			ws.Follow(failover.EndpointAt(clientIndex).RPCURL())
+		}
+		if len(ladder) > 0 {
+			fee = ladder[ladderIndex%len(ladder)]
+			pingWithFee = cConf.PingConfig.FeeLadder[ladderIndex%len(ladder)] > 0
+			ladderIndex++
+		}
+		pingStart := time.Now()
+		result, err := Ping(c, DataPoint1Min, acct, cConf, pingTx, ws, fee, pingWithFee)
+		if cConf.PingConfig.ComputeFeeDualMode || len(ladder) > 0 {
 			if !pingWithFee {
 				result.ComputeUnitPrice = 0
 				result.RequestComputeUnits = 0
+				if len(ladder) > 0 { // the 0 tier keeps its ladder inputs, ladder prices are fixed and need no query
+					feePrice := fee.Price(c, acct)
+					result.FeeStrategy = string(feePrice.Strategy)
+					result.FeeInputs = feePrice.Inputs
+				}
 			}
 		}
 		addRecord(result)
+		recordPingMetrics(result)
+		pingStream.Publish(result)
 		if influxdb != nil && influxdb.Client != nil {
 			influxdb.SendDatapointAsync(influxdb.PrepareInfluxdbData(result))
 		}
-		failover.GetEndpoint().RetryResult(err)
-		extraTimeStop := time.Now().UTC().Unix()
-		waitTime := cConf.ClusterPing.PingConfig.MinPerPingTime - (result.TakeTime / 1000) - (extraTimeStop - extraTimeStart)
-		if waitTime > 0 {
-			time.Sleep(time.Duration(waitTime) * time.Second)
+		failover.EndpointAt(clientIndex).RetryResult(err)
+		// pace by the whole time of the ping, which also has the time out of TakeTime, e.g. the blockhash, slots and finalized
+		waitTime := time.Duration(cConf.ClusterPing.PingConfig.MinPerPingTime)*time.Second - time.Since(pingStart)
+		if waitTime > 0 && !sleepContext(ctx, waitTime) {
+			return
 		}
 		if cConf.PingConfig.ComputeFeeDualMode {
 			pingWithFee = !pingWithFee
@@ -120,10 +184,10 @@ func pingDataWorker(cConf ClusterConfig, workerNum int) {
 	}
 }
 
-func retensionServiceWorker() {
+func retensionServiceWorker(ctx context.Context) {
 	log.Println(">> Retension Service Worker start!")
 	defer log.Println(">> Retension Service Worker end!")
-	for {
+	for ctx.Err() == nil {
 		now := time.Now().UTC().Unix()
 		if config.Retension.KeepHours < 6 {
 			config.Retension.KeepHours = 6
@@ -133,7 +197,7 @@ func retensionServiceWorker() {
 		if config.Retension.UpdateIntervalSec < 300 {
 			config.Retension.UpdateIntervalSec = 300
 		}
-		time.Sleep(time.Duration(config.Retension.UpdateIntervalSec) * time.Second)
+		sleepContext(ctx, time.Duration(config.Retension.UpdateIntervalSec)*time.Second)
 	}
 }
 
@@ -154,7 +218,7 @@ func getConfigKeyPair(c SolanaCLIConfig) (types.Account, error) {
 	return acct, nil
 }
 
-func reportWorker(cConf ClusterConfig) {
+func reportWorker(ctx context.Context, cConf ClusterConfig) {
 	log.Println(">> Report Worker for ", cConf.Cluster, " start!")
 	defer log.Println(">> Report Worker for ", cConf.Cluster, " end!")
 	var lastReporTime int64
@@ -164,8 +228,14 @@ func reportWorker(cConf ClusterConfig) {
 	if cConf.PingConfig.ComputeUnitPrice > 0 && cConf.PingConfig.ComputeFeeDualMode {
 		triggerNoFee = NewAlertTriggerByParams(DualModeNoFeeTriggerName, cConf.Report.LevelFilePath+".nofee", cConf.LossThreshold)
 	}
+	// each tier of FeeLadder has its own trigger
+	triggerLadder := make([]AlertTrigger, len(cConf.PingConfig.FeeLadder))
+	for i, price := range cConf.PingConfig.FeeLadder {
+		triggerLadder[i] = NewAlertTriggerByParams(fmt.Sprintf("%s%d", FeeLadderTriggerPrefix, price),
+			fmt.Sprintf("%s.tier%d", cConf.Report.LevelFilePath, price), cConf.LossThreshold)
+	}
 
-	for {
+	for ctx.Err() == nil {
 		now := time.Now().UTC().Unix()
 		if lastReporTime == 0 { // server restart will cause lasterReportTime zero
 			lastReporTime = now - int64(cConf.Report.Interval)
@@ -175,17 +245,13 @@ func reportWorker(cConf ClusterConfig) {
 			discordReportEnabled bool, discordAlertEnabled bool,
 			groupStatistic *GroupsAllStatistic, globalStatistic GlobalStatistic,
 			toSendAlert bool, alertTrigger AlertTrigger, messageMemo string) {
-			var accessToken string
-			switch cConf.Cluster {
-			case MainnetBeta:
-				accessToken = mainnetFailover.GetEndpoint().AccessToken
-			case Testnet:
-				accessToken = testnetFailover.GetEndpoint().AccessToken
-			case Devnet:
-				accessToken = devnetFailover.GetEndpoint().AccessToken
-			default:
-				panic(fmt.Sprintf("%s:%s", "no such cluster", cConf.Cluster))
+			accessToken := GetClusterFailover(cConf.Cluster).GetEndpoint().AccessToken
+			if toSendAlert && alertTrigger.LevelChanged() {
+				if err := addAlertEvent(alertTrigger.NewAlertEvent(cConf, messageMemo)); err != nil {
+					log.Println(cConf.Cluster, " add alert event error:", err)
+				}
 			}
+
 			if slackReportEnabled {
 				slackReportSend(cConf, groupStatistic, &globalStatistic, []string{accessToken}, messageMemo)
 			}
@@ -202,21 +268,43 @@ func reportWorker(cConf ClusterConfig) {
 			}
 		}
 		getDataFromComputeFee := AllData
-		if cConf.PingConfig.ComputeUnitPrice > 0 && cConf.PingConfig.RequestUnits > 0 {
+		if cConf.PingConfig.ComputeUnitPrice > 0 && cConf.PingConfig.RequestUnits > 0 && len(cConf.PingConfig.FeeLadder) == 0 {
 			getDataFromComputeFee = HasComputeUnitPrice
 		}
 		data := getAfter(cConf.Cluster, DataPoint1Min, lastReporTime, getDataFromComputeFee, 0)
 		if len(data) <= 0 { // No Data
 			log.Println(cConf.Cluster, " getAfter return empty")
-			time.Sleep(30 * time.Second)
+			sleepContext(ctx, 30*time.Second)
 			continue
 		}
 		groupsStat, globalStat := getGlobalStatistis(cConf, data, lastReporTime, now)
 		trigger.Update(globalStat.Loss)
 		// ShouldAlertSend execute once only. TODO: make shouldAlertSend a function which does not modify any value
 		alertSend := trigger.ShouldAlertSend()
+		// FeeLadder section and alert of each tier. Tiers are in the main report and only alerts are sent separately
+		for i, price := range cConf.PingConfig.FeeLadder {
+			dataTier := []PingResult{}
+			for _, r := range data {
+				if r.ComputeUnitPrice == price {
+					dataTier = append(dataTier, r)
+				}
+			}
+			tierStat := FeeTierStatistic{ComputeUnitPrice: price}
+			if len(dataTier) > 0 {
+				groupsStatTier, globalStatTier := getGlobalStatistis(cConf, dataTier, lastReporTime, now)
+				tierStat.GlobalStatistic = globalStatTier
+				triggerLadder[i].Update(globalStatTier.Loss)
+				if triggerLadder[i].ShouldAlertSend() {
+					sendReportAlert(false, cConf.Report.Slack.Alert.Enabled, false, cConf.Report.Discord.Alert.Enabled,
+						groupsStatTier, globalStatTier, true, triggerLadder[i], fmt.Sprintf("fee-ladder tier %d (%vml)", i, price))
+				}
+			}
+			groupsStat.FeeTiers = append(groupsStat.FeeTiers, tierStat)
+		}
 		messageMemo := ""
-		if cConf.PingConfig.ComputeUnitPrice > 0 {
+		if len(cConf.PingConfig.FeeLadder) > 0 {
+			messageMemo = fmt.Sprintf("fee-ladder (%d tiers)", len(cConf.PingConfig.FeeLadder))
+		} else if cConf.PingConfig.ComputeUnitPrice > 0 {
 			// count txs by fee
 			m := map[uint64]uint64{}
 			for _, v := range data {
@@ -237,7 +325,12 @@ func reportWorker(cConf ClusterConfig) {
 				outputs = append(outputs, fmt.Sprintf("%vtx@%vml", txCount, computeUnitPrice))
 			}
 
-			messageMemo = fmt.Sprintf("with-fee (max: 10^8 ml), ({count}tx@{compute_unit_price}ml: %v)", strings.Join(outputs, ","))
+			feeCap := cConf.PingConfig.FeeStrategy.Cap
+			if feeCap == 0 {
+				feeCap = DefaultFeeCap
+			}
+			messageMemo = fmt.Sprintf("with-fee (max: %v ml), ({count}tx@{compute_unit_price}ml: %v), strategies: %v",
+				feeCap, strings.Join(outputs, ","), feeStrategyMemo(data))
 		} else {
 			messageMemo = "no-fee"
 		}
@@ -261,7 +354,7 @@ func reportWorker(cConf ClusterConfig) {
 			}
 		}
 		lastReporTime = now
-		time.Sleep(time.Duration(cConf.Report.Interval) * time.Second)
+		sleepContext(ctx, time.Duration(cConf.Report.Interval)*time.Second)
 	}
 }
 
@@ -302,7 +395,7 @@ func discordAlertSend(cConf ClusterConfig, globalStat *GlobalStatistic, globalEr
 }
 
 func getGlobalStatistis(cConf ClusterConfig, resutls []PingResult, lastReportTime int64, currentTime int64) (*GroupsAllStatistic, GlobalStatistic) {
-	groups := grouping1Min(resutls, lastReportTime, currentTime)
+	groups := groupingWindow(resutls, lastReportTime, currentTime, cConf.Report.GroupWindow)
 	groupsStat := statisticCompute(cConf, groups)
 	return groupsStat, groupsStat.GetGroupsAllStatistic(false) // get raw data
 }